@@ -15,6 +15,9 @@ func main() {
 		Commands: []*cli.Command{
 			cmd.NewServeCommand(),
 			cmd.NewGenerateCommand(),
+			cmd.NewCertsCommand(),
+			cmd.NewLoadCommand(),
+			cmd.NewReplayCommand(),
 		},
 		DefaultCommand: "serve",
 		Flags: []cli.Flag{&cli.StringFlag{
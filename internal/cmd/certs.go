@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/template"
+	"github.com/urfave/cli/v2"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+func NewCertsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "certs",
+		Usage: "Generate CA, server and client certificates for certificate demo scenarios.",
+		Action: func(ctx *cli.Context) error {
+			opts := certOptions{
+				output:         ctx.String("output"),
+				name:           sanitizeName(ctx.String("name")),
+				hosts:          ctx.StringSlice("host"),
+				caValidFor:     ctx.Duration("ca-valid-for"),
+				serverValidFor: ctx.Duration("server-valid-for"),
+				clientValidFor: ctx.Duration("client-valid-for"),
+				secrets:        ctx.Bool("secrets"),
+				chart:          ctx.String("chart"),
+			}
+			slog.Info("Generating certificates", "output", opts.output, "serverValidFor", opts.serverValidFor)
+			if err := generateCerts(opts); err != nil {
+				return err
+			}
+			slog.Info("🎉 Done!")
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Value:   "certs",
+				Usage:   "Output directory",
+			},
+			&cli.StringFlag{
+				Name:    "name",
+				Aliases: []string{"n"},
+				Value:   "sim",
+				Usage:   "Name used for certificate subjects and secrets",
+			},
+			&cli.StringSliceFlag{
+				Name:  "host",
+				Value: cli.NewStringSlice("localhost", "127.0.0.1"),
+				Usage: "DNS names and IP addresses of the server certificate",
+			},
+			&cli.DurationFlag{
+				Name:  "ca-valid-for",
+				Value: 10 * 365 * 24 * time.Hour,
+				Usage: "Validity of the CA certificate",
+			},
+			&cli.DurationFlag{
+				Name:  "server-valid-for",
+				Value: 365 * 24 * time.Hour,
+				Usage: "Validity of the server certificate, e.g. 10m to expire soon or -1h for an expired certificate",
+			},
+			&cli.DurationFlag{
+				Name:  "client-valid-for",
+				Value: 365 * 24 * time.Hour,
+				Usage: "Validity of the client certificate, e.g. 10m to expire soon or -1h for an expired certificate",
+			},
+			&cli.BoolFlag{
+				Name:  "secrets",
+				Usage: "Also write Kubernetes TLS Secret manifests to the output directory",
+			},
+			&cli.StringFlag{
+				Name:  "chart",
+				Usage: "Helm chart directory to write the TLS Secret manifests to, e.g. one created by the generate command",
+			},
+		},
+	}
+}
+
+type certOptions struct {
+	output         string
+	name           string
+	hosts          []string
+	caValidFor     time.Duration
+	serverValidFor time.Duration
+	clientValidFor time.Duration
+	secrets        bool
+	chart          string
+}
+
+type certKeyPair struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func generateCerts(opts certOptions) error {
+	mustMkdirAll(opts.output)
+	ca, err := newCertificate(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("%s-ca", opts.name)},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}, opts.caValidFor, nil)
+	if err != nil {
+		return err
+	}
+
+	serverTemplate := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: fmt.Sprintf("%s-server", opts.name)},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range opts.hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+		}
+	}
+	server, err := newCertificate(serverTemplate, opts.serverValidFor, ca)
+	if err != nil {
+		return err
+	}
+
+	client, err := newCertificate(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: fmt.Sprintf("%s-client", opts.name)},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, opts.clientValidFor, ca)
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		"ca.pem":         ca.certPEM,
+		"ca-key.pem":     ca.keyPEM,
+		"server.pem":     server.certPEM,
+		"server-key.pem": server.keyPEM,
+		"client.pem":     client.certPEM,
+		"client-key.pem": client.keyPEM,
+	}
+	for file, content := range files {
+		if err = os.WriteFile(appendPath(opts.output, file), content, 0600); err != nil {
+			return err
+		}
+	}
+
+	if opts.secrets {
+		writeSecrets(opts.output, opts.name, "", ca, server, client)
+	}
+	if opts.chart != "" {
+		templateDir := mustMkdirAll(appendPath(opts.chart, "templates"))
+		writeSecrets(templateDir, opts.name, helmLabels, ca, server, client)
+	}
+	return nil
+}
+
+// newCertificate creates a key pair and certificate valid from now for validFor. A negative validFor creates
+// an already expired certificate. The certificate is self-signed when no parent is given.
+func newCertificate(tpl *x509.Certificate, validFor time.Duration, parent *certKeyPair) (*certKeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tpl.SerialNumber = serial
+	tpl.NotBefore = now.Add(-time.Hour)
+	tpl.NotAfter = now.Add(validFor)
+	if tpl.NotAfter.Before(tpl.NotBefore) {
+		tpl.NotBefore = tpl.NotAfter.Add(-24 * time.Hour)
+	}
+
+	signerCert, signerKey := tpl, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &certKeyPair{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}),
+	}, nil
+}
+
+func writeSecrets(dir, name, labels string, ca, server, client *certKeyPair) {
+	for suffix, pair := range map[string]*certKeyPair{"server": server, "client": client} {
+		secretName := fmt.Sprintf("%s-%s-tls", name, suffix)
+		template.MustRenderToFile(tlsSecretTemplate, appendPath(dir, fmt.Sprintf("%s-secret.yaml", secretName)), template.DataMap{
+			"name":   secretName,
+			"labels": labels,
+			"caCrt":  base64.StdEncoding.EncodeToString(ca.certPEM),
+			"tlsCrt": base64.StdEncoding.EncodeToString(pair.certPEM),
+			"tlsKey": base64.StdEncoding.EncodeToString(pair.keyPEM),
+		})
+	}
+}
+
+const helmLabels = `
+  labels:
+    {{- include "common.labels" . | nindent 4 }}`
+
+const tlsSecretTemplate = `apiVersion: v1
+kind: Secret
+metadata:
+  name: [[name]][[labels]]
+type: kubernetes.io/tls
+data:
+  ca.crt: [[caCrt]]
+  tls.crt: [[tlsCrt]]
+  tls.key: [[tlsKey]]
+`
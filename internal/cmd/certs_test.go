@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerateCerts(t *testing.T) {
+	tempDir := t.TempDir()
+	chartDir := filepath.Join(tempDir, "chart")
+	err := generateCerts(certOptions{
+		output:         tempDir,
+		name:           "sim",
+		hosts:          []string{"localhost", "127.0.0.1"},
+		caValidFor:     time.Hour,
+		serverValidFor: -time.Hour,
+		clientValidFor: 10 * time.Minute,
+		secrets:        true,
+		chart:          chartDir,
+	})
+	require.NoError(t, err)
+
+	server := loadTestCert(t, tempDir, "server")
+	require.True(t, server.NotAfter.Before(time.Now()))
+	require.Equal(t, []string{"localhost"}, server.DNSNames)
+
+	client := loadTestCert(t, tempDir, "client")
+	require.WithinDuration(t, time.Now().Add(10*time.Minute), client.NotAfter, time.Minute)
+
+	ca := loadTestCert(t, tempDir, "ca")
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	_, err = client.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	require.NoError(t, err)
+
+	require.FileExists(t, filepath.Join(tempDir, "sim-server-tls-secret.yaml"))
+	secret, err := os.ReadFile(filepath.Join(chartDir, "templates", "sim-client-tls-secret.yaml"))
+	require.NoError(t, err)
+	require.Contains(t, string(secret), `include "common.labels"`)
+}
+
+func loadTestCert(t *testing.T, dir, name string) *x509.Certificate {
+	pair, err := tls.LoadX509KeyPair(filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem"))
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	require.NoError(t, err)
+	return cert
+}
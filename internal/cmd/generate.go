@@ -100,6 +100,19 @@ func getConfigDataFunc(config *ConfigData) template.DataFunc {
 				indentContent := bytes.ReplaceAll(config.Content, []byte("\n"), []byte("\n     "))
 				return w.Write(indentContent)
 
+			case "grpcContainerPort":
+				if !config.Config.Grpc.Enabled {
+					return 0, nil
+				}
+				return w.Write([]byte(fmt.Sprintf("\n        - name: grpc\n          containerPort: %d", config.Config.Grpc.Port)))
+
+			case "grpcServicePort":
+				if !config.Config.Grpc.Enabled {
+					return 0, nil
+				}
+				port := config.Config.Grpc.Port
+				return w.Write([]byte(fmt.Sprintf("\n    - name: grpc\n      protocol: TCP\n      port: %d\n      targetPort: %d", port, port)))
+
 			default:
 				return w.Write([]byte(fmt.Sprintf("[unknown tag %q]", tag)))
 			}
@@ -202,6 +215,7 @@ fullnameOverride: ''
 otelHttpEndpoint: opentelemetry-collector.open-telemetry.svc.cluster.local:4318
 traceEnabled: false
 metricsEnabled: false
+prometheusEnabled: false
 image: ravan/mockroservice:latest
 resources:
   requests:
@@ -296,6 +310,11 @@ data:
      enabled = {{.Values.metricsEnabled}}
      http-endpoint = "{{.Values.otelHttpEndpoint}}" 
      insecure = true
+
+     [otel.prometheus]
+     enabled = {{.Values.prometheusEnabled}}
+     path = "/metrics"
+     port = 9464
 `
 
 const deploymentTemplate = `apiVersion: apps/v1
@@ -318,6 +337,11 @@ spec:
         service: [[serviceName]] 
       annotations:
         checksum/config: '{{ include (print $.Template.BasePath "/[[serviceName]]-cm.yaml") . | sha256sum}}'
+        {{- if .Values.prometheusEnabled }}
+        prometheus.io/scrape: "true"
+        prometheus.io/port: "9464"
+        prometheus.io/path: /metrics
+        {{- end }}
     spec:
       containers:
       - name: [[serviceName]]
@@ -325,14 +349,51 @@ spec:
         env:
         - name: CONFIG_FILE
           value: /etc/app/config.toml
+        - name: MEMORY_REQUEST
+          valueFrom:
+            resourceFieldRef:
+              resource: requests.memory
+        - name: CPU_REQUEST_MILLICORES
+          valueFrom:
+            resourceFieldRef:
+              resource: requests.cpu
+              divisor: 1m
+        - name: CPU_REQUEST
+          value: "$(CPU_REQUEST_MILLICORES)m"
+        - name: K8S_POD_NAME
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.name
+        - name: K8S_POD_UID
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.uid
+        - name: K8S_NAMESPACE_NAME
+          valueFrom:
+            fieldRef:
+              fieldPath: metadata.namespace
+        - name: K8S_NODE_NAME
+          valueFrom:
+            fieldRef:
+              fieldPath: spec.nodeName
+        - name: OTEL_RESOURCE_ATTRIBUTES
+          value: "k8s.pod.name=$(K8S_POD_NAME),k8s.pod.uid=$(K8S_POD_UID),k8s.namespace.name=$(K8S_NAMESPACE_NAME),k8s.node.name=$(K8S_NODE_NAME),k8s.deployment.name=[[serviceName]],k8s.container.name=[[serviceName]]"
         ports:
-        - containerPort: 8080
+        - containerPort: 8080[[grpcContainerPort]]
+        {{- if .Values.prometheusEnabled }}
+        - name: metrics
+          containerPort: 9464
+        {{- end }}
         resources:
           {{- toYaml .Values.resources | nindent 12 }} 
         volumeMounts:
         - name: config-volume
           mountPath: /etc/app
+        - name: state-volume
+          mountPath: /tmp
       volumes:
+      - name: state-volume
+        emptyDir: {}
       - name: config-volume
         configMap:
           name: [[serviceName]]-cm
@@ -353,8 +414,9 @@ spec:
     service: [[serviceName]]
     {{- include "common.selectorLabels" . | nindent 4 }}
   ports:
-    - protocol: TCP
+    - name: http
+      protocol: TCP
       port: 80      # Service port
-      targetPort: 8080 # Container port
+      targetPort: 8080 # Container port[[grpcServicePort]]
   type: ClusterIP     # Internal service within the Kubernetes cluster
 `
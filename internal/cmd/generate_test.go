@@ -32,6 +32,19 @@ func TestGenerate(t *testing.T) {
 		return nil
 	})
 	require.NoError(t, err)
+
+	deployment, err := os.ReadFile(fmt.Sprintf("%s/templates/triceratops-transport-deployment.yaml", chartDir))
+	require.NoError(t, err)
+	require.Contains(t, string(deployment), "k8s.deployment.name=triceratops-transport")
+	require.Contains(t, string(deployment), "fieldPath: metadata.namespace")
+	require.NotContains(t, string(deployment), "name: grpc")
+
+	deployment, err = os.ReadFile(fmt.Sprintf("%s/templates/t-rex-tracker-deployment.yaml", chartDir))
+	require.NoError(t, err)
+	require.Contains(t, string(deployment), "- name: grpc\n          containerPort: 9090\n")
+	service, err := os.ReadFile(fmt.Sprintf("%s/templates/t-rex-tracker-svc.yaml", chartDir))
+	require.NoError(t, err)
+	require.Contains(t, string(service), "- name: grpc\n      protocol: TCP\n      port: 9090\n      targetPort: 9090\n")
 }
 
 const testConfig = `
@@ -73,6 +86,13 @@ body.msg = "T-Rex safely tracked"
 uri = "Triceratops Transport/route"
 delay = "50ms"  # T-Rex Tracker depends on Triceratops to report tracking data
 
+[grpc]
+enabled = true
+
+[[grpc.endpoints]]
+uri = "park.Tracker/Locate"
+body.status = "ok"
+
 +++
 
 # Herbivore Hideout
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/load"
+	"github.com/ravan/microservice-sim/internal/otel"
+	"github.com/urfave/cli/v2"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func NewLoadCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "load",
+		Usage: "Generate traffic against one or more endpoints and report latencies and errors.",
+		Action: func(ctx *cli.Context) error {
+			opts, err := getLoadOptions(ctx)
+			if err != nil {
+				return cli.Exit(err.Error(), 1)
+			}
+			runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			shutdown, err := initClientTracing(ctx, runCtx)
+			if err != nil {
+				return err
+			}
+			defer shutdown()
+
+			slog.Info("Generating load", "targets", len(opts.Targets), "mode", opts.Mode)
+			_, err = load.Run(runCtx, opts)
+			return err
+		},
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:     "target",
+				Aliases:  []string{"t"},
+				Required: true,
+				Usage:    "Target as \"[METHOD ]URL[ WEIGHT]\", repeat to define a request mix",
+			},
+			&cli.Float64Flag{
+				Name:  "rps",
+				Value: 10,
+				Usage: "Requests per second for the whole duration",
+			},
+			&cli.IntFlag{
+				Name:  "concurrency",
+				Usage: "Number of concurrent workers each sending requests back to back, instead of a fixed rate",
+			},
+			&cli.DurationFlag{
+				Name:    "duration",
+				Aliases: []string{"d"},
+				Value:   30 * time.Second,
+				Usage:   "Duration of the load when no stages are defined",
+			},
+			&cli.StringSliceFlag{
+				Name:  "stage",
+				Usage: "Stage as \"DURATION:VALUE\" ramping the rate, or the workers with --concurrency, to VALUE, e.g. 30s:100. A 0s stage jumps to VALUE",
+			},
+			&cli.StringSliceFlag{
+				Name:    "header",
+				Aliases: []string{"H"},
+				Usage:   "Request header as \"Key: Value\"",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Value: 10 * time.Second,
+				Usage: "Request timeout",
+			},
+			&cli.DurationFlag{
+				Name:  "report-interval",
+				Value: 5 * time.Second,
+				Usage: "Interval of progress reports, 0 to only report the summary",
+			},
+		},
+	}
+}
+
+func getLoadOptions(ctx *cli.Context) (load.Options, error) {
+	opts := load.Options{
+		Mode:           load.ModeRate,
+		Headers:        http.Header{},
+		Timeout:        ctx.Duration("timeout"),
+		ReportInterval: ctx.Duration("report-interval"),
+		Out:            os.Stdout,
+	}
+	for _, t := range ctx.StringSlice("target") {
+		target, err := load.ParseTarget(t)
+		if err != nil {
+			return opts, err
+		}
+		opts.Targets = append(opts.Targets, target)
+	}
+	for _, header := range ctx.StringSlice("header") {
+		key, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return opts, fmt.Errorf("invalid header %q, expected \"Key: Value\"", header)
+		}
+		opts.Headers.Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+
+	value := ctx.Float64("rps")
+	if ctx.IsSet("concurrency") {
+		if ctx.IsSet("rps") {
+			return opts, fmt.Errorf("either --rps or --concurrency can be set")
+		}
+		opts.Mode = load.ModeConcurrency
+		value = float64(ctx.Int("concurrency"))
+	}
+	for _, s := range ctx.StringSlice("stage") {
+		stage, err := load.ParseStage(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.Stages = append(opts.Stages, stage)
+	}
+	if len(opts.Stages) == 0 {
+		// steady load, jump to the value and hold it for the duration
+		opts.Stages = []load.Stage{{Value: value}, {Duration: ctx.Duration("duration"), Value: value}}
+	}
+	return opts, nil
+}
+
+// initClientTracing sets up tracing for commands sending requests, exporting with the otel settings of the config.
+func initClientTracing(cliCtx *cli.Context, ctx context.Context) (func(), error) {
+	conf, err := getConfig(cliCtx)
+	if err != nil {
+		return nil, err
+	}
+	if !conf.OpenTelemetry.Trace.Enabled {
+		otel.NewTracer(conf.OpenTelemetry)
+		return func() {}, nil
+	}
+	shutdown, err := otel.InitializeOpenTelemetry(ctx, conf.OpenTelemetry)
+	if err != nil {
+		return nil, err
+	}
+	otel.NewTracer(conf.OpenTelemetry)
+	return func() {
+		if err := shutdown(context.Background()); err != nil {
+			slog.Error("Error shutting down otel:", slog.Any("error", err))
+		}
+	}, nil
+}
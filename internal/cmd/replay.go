@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"github.com/ravan/microservice-sim/internal/record"
+	"github.com/urfave/cli/v2"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func NewReplayCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "replay",
+		Usage: "Replay requests recorded by the recorder against a target.",
+		Action: func(ctx *cli.Context) error {
+			file, err := os.Open(ctx.String("file"))
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			info, err := file.Stat()
+			if err != nil {
+				return err
+			}
+
+			runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			shutdown, err := initClientTracing(ctx, runCtx)
+			if err != nil {
+				return err
+			}
+			defer shutdown()
+
+			slog.Info("Replaying requests", "file", ctx.String("file"), "target", ctx.String("target"), "speed", ctx.Float64("speed"))
+			// only replay what was recorded so far, the target may be recording to the same file
+			result, err := record.Replay(runCtx, io.LimitReader(file, info.Size()), record.ReplayOptions{
+				Target:  ctx.String("target"),
+				Speed:   ctx.Float64("speed"),
+				Timeout: ctx.Duration("timeout"),
+			})
+			if err != nil {
+				return err
+			}
+			result.Write(os.Stdout)
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "file",
+				Aliases: []string{"f"},
+				Value:   "requests.jsonl",
+				Usage:   "JSONL file written by the recorder",
+			},
+			&cli.StringFlag{
+				Name:     "target",
+				Aliases:  []string{"t"},
+				Required: true,
+				Usage:    "Base url requests are sent to, e.g. localhost:8080",
+			},
+			&cli.Float64Flag{
+				Name:  "speed",
+				Value: 1,
+				Usage: "Speed multiplier of the recorded timing, 2 replays twice as fast, 0 as fast as possible",
+			},
+			&cli.DurationFlag{
+				Name:  "timeout",
+				Value: 10 * time.Second,
+				Usage: "Request timeout",
+			},
+		},
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/ravan/microservice-sim/internal/util"
 	"github.com/spf13/viper"
 	"log/slog"
+	"os"
 	"path"
 	"path/filepath"
 	"strings"
@@ -17,19 +18,38 @@ type Configuration struct {
 	Address       string       `mapstructure:"address" validate:"required"`
 	Port          int          `mapstructure:"port" validate:"required"`
 	LogLevel      string       `mapstructure:"logLevel"`
+	HealthUri     string       `mapstructure:"healthUri"`
 	Logging       util.Logging `mapstructure:"logging"`
+	TraceLog      TraceLog     `mapstructure:"traceLog"`
 	Certificate   Certificate  `mapstructure:"certificate"`
-	Endpoints     []Endpoint   `mapstructure:"endpoints"`
+	Crash         Crash        `mapstructure:"crash"`
+	Endpoints     []Endpoint   `mapstructure:"endpoints" validate:"dive"`
 	MemStress     MemStress    `mapstructure:"memstress" `
 	StressNg      StressNg     `mapstructure:"stressng" `
+	CpuStress     CpuStress    `mapstructure:"cpustress" `
+	Resources     Resources    `mapstructure:"resources" `
+	Recorder      Recorder     `mapstructure:"recorder" `
+	Grpc          Grpc         `mapstructure:"grpc" `
 	OpenTelemetry OtelConfig   `mapstructure:"otel"`
 }
 
+// TraceLog adds the trace and span id of the request to log lines written to stderr.
+type TraceLog struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Convention string `mapstructure:"convention" validate:"omitempty,oneof=w3c datadog elastic"`
+	TraceIdKey string `mapstructure:"traceIdKey"`
+	SpanIdKey  string `mapstructure:"spanIdKey"`
+}
+
 type Certificate struct {
-	Enabled       bool   `mapstructure:"enabled"`
-	Delay         string `mapstructure:"delay" `
-	CertFile      string `mapstructure:"certificate" validate:"required_with=Enabled"`
-	KeyFile       string `mapstructure:"key" validate:"required_with=Enabled"`
+	Enabled       bool     `mapstructure:"enabled"`
+	Delay         string   `mapstructure:"delay" `
+	CertFile      string   `mapstructure:"certificate" validate:"required_with=Enabled"`
+	KeyFile       string   `mapstructure:"key" validate:"required_with=Enabled"`
+	ExitOnExpired bool     `mapstructure:"exitOnExpired"`
+	CheckInterval string   `mapstructure:"checkInterval"`
+	WarnBefore    []string `mapstructure:"warnBefore"`
+	FailOnExpiry  string   `mapstructure:"failOnExpiry" validate:"omitempty,oneof=none requests health all"`
 	mutex         sync.Mutex
 	delayDuration *util.Delay
 }
@@ -43,16 +63,36 @@ func (c *Certificate) GetDelayDuration() *util.Delay {
 	return c.delayDuration
 }
 
+type Crash struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	Mode           string `mapstructure:"mode" validate:"omitempty,oneof=exit panic segfault"`
+	ExitCode       int    `mapstructure:"exitCode"`
+	Message        string `mapstructure:"message"`
+	Delay          string `mapstructure:"delay"`
+	AfterRequests  int    `mapstructure:"afterRequests"`
+	OnEndpoint     string `mapstructure:"onEndpoint"`
+	CrashingStarts int    `mapstructure:"crashingStarts"`
+	StateFile      string `mapstructure:"stateFile"`
+}
+
 type Endpoint struct {
 	Uri           string                 `mapstructure:"uri" validate:"required"`
 	Delay         string                 `mapstructure:"delay" `
+	CpuWork       string                 `mapstructure:"cpuWork" `
+	Memory        RequestMemory          `mapstructure:"memory" `
+	Leaks         Leaks                  `mapstructure:"leaks" `
 	ErrorOnCall   int                    `mapstructure:"errorOnCall"`
 	ErrorLogging  util.Logging           `mapstructure:"errorLogging"`
 	Logging       util.Logging           `mapstructure:"logging"`
 	Body          map[string]interface{} `mapstructure:"body" `
 	Routes        []Route                `mapstructure:"routes" `
+	Spans         []Span                 `mapstructure:"spans" validate:"dive"`
 	mutex         sync.Mutex
 	delayDuration *util.Delay
+	cpuWork       *util.CpuWork
+
+	// SpanAnnotations are added to the server span of the request.
+	util.SpanAnnotations `mapstructure:",squash"`
 }
 
 func (e *Endpoint) GetDelayDuration() *util.Delay {
@@ -64,26 +104,115 @@ func (e *Endpoint) GetDelayDuration() *util.Delay {
 	return e.delayDuration
 }
 
+func (e *Endpoint) GetCpuWork() *util.CpuWork {
+	if e.cpuWork == nil {
+		e.mutex.Lock()
+		e.cpuWork = util.ParseCpuWork(e.CpuWork)
+		e.mutex.Unlock()
+	}
+	return e.cpuWork
+}
+
+type RequestMemory struct {
+	Allocate string `mapstructure:"allocate"`
+	Leak     bool   `mapstructure:"leak"`
+	LeakCap  string `mapstructure:"leakCap"`
+}
+
+type Leaks struct {
+	Goroutines  LeakStress `mapstructure:"goroutines"`
+	Files       LeakStress `mapstructure:"files"`
+	Connections LeakStress `mapstructure:"connections"`
+}
+
+type LeakStress struct {
+	Enabled    bool `mapstructure:"enabled"`
+	PerRequest int  `mapstructure:"perRequest"`
+	Cap        int  `mapstructure:"cap"`
+}
+
 type StressNg struct {
-	Enabled bool     `mapstructure:"enabled" `
-	Delay   string   `mapstructure:"delay"`
-	Args    []string `mapstructure:"args" `
+	Enabled     bool     `mapstructure:"enabled" `
+	Delay       string   `mapstructure:"delay"`
+	Args        []string `mapstructure:"args" `
+	Duration    string   `mapstructure:"duration"`
+	Repeat      string   `mapstructure:"repeat"`
+	OutputLevel string   `mapstructure:"outputLevel"`
+}
+
+type Resources struct {
+	Basis         string `mapstructure:"basis" validate:"omitempty,oneof=auto host limit request"`
+	MemoryRequest string `mapstructure:"memoryRequest"`
+	CpuRequest    string `mapstructure:"cpuRequest"`
+}
+
+type CpuStress struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Delay    string `mapstructure:"delay"`
+	Backend  string `mapstructure:"backend" validate:"omitempty,oneof=native stress-ng"`
+	Load     string `mapstructure:"load" validate:"required_with=Enabled"`
+	Duration string `mapstructure:"duration"`
+	RampTime string `mapstructure:"rampTime"`
 }
 
 type MemStress struct {
-	Enabled    bool   `mapstructure:"enabled"`
-	Delay      string `mapstructure:"delay"`
-	MemSize    string `mapstructure:"memSize" validate:"required_with=Enabled"`
-	GrowthTime string `mapstructure:"growthTime" validate:"required_with=Enabled"`
+	Enabled      bool   `mapstructure:"enabled"`
+	Delay        string `mapstructure:"delay"`
+	Pattern      string `mapstructure:"pattern" validate:"omitempty,oneof=linear sawtooth oscillate step release oom"`
+	MemSize      string `mapstructure:"memSize" validate:"required_with=Enabled"`
+	MinSize      string `mapstructure:"minSize"`
+	GrowthTime   string `mapstructure:"growthTime" validate:"required_with=Enabled"`
+	Period       string `mapstructure:"period"`
+	StepSize     string `mapstructure:"stepSize"`
+	StepInterval string `mapstructure:"stepInterval"`
+	HoldTime     string `mapstructure:"holdTime"`
+}
+
+// Span is a synthetic span recorded as a child of the request, modelling in-process work or a call to a database,
+// cache or message broker. Spans are run in order, nested spans run within their parent.
+type Span struct {
+	Name string `mapstructure:"name" validate:"required"`
+	// Preset adds the semantic convention attributes and span kind of a db, cache or messaging call.
+	Preset string `mapstructure:"preset" validate:"omitempty,oneof=db cache messaging"`
+	// Kind overrides the span kind of the preset, internal by default.
+	Kind string `mapstructure:"kind" validate:"omitempty,oneof=internal client server producer consumer"`
+	// System is the db.system or messaging.system, defaults to postgresql, redis or kafka depending on the preset.
+	System string `mapstructure:"system"`
+	// Target is the database name of db and cache presets or the destination of the messaging preset.
+	Target    string `mapstructure:"target"`
+	Operation string `mapstructure:"operation"`
+	Statement string `mapstructure:"statement"`
+	// Duration is the time spent in the span excluding nested spans, e.g. "5ms" or a range "5ms-20ms".
+	Duration   string                 `mapstructure:"duration"`
+	ErrorRate  float64                `mapstructure:"errorRate" validate:"gte=0,lte=1"`
+	StopOnFail bool                   `mapstructure:"stopOnFail"`
+	Attributes map[string]interface{} `mapstructure:"attributes"`
+	Spans      []Span                 `mapstructure:"spans" validate:"dive"`
+	mutex      sync.Mutex
+	duration   *util.DurationRange
+}
+
+func (s *Span) GetDuration() *util.DurationRange {
+	if s.duration == nil {
+		s.mutex.Lock()
+		s.duration = util.ParseDurationRange(s.Duration)
+		s.mutex.Unlock()
+	}
+	return s.duration
 }
 
 type Route struct {
 	Uri           string       `mapstructure:"uri" validate:"required"`
 	Delay         string       `mapstructure:"delay" `
+	CpuWork       string       `mapstructure:"cpuWork" `
 	StopOnFail    bool         `mapstructure:"stopOnFail"`
 	Logging       util.Logging `mapstructure:"logging"`
 	mutex         sync.Mutex
 	delayDuration *util.Delay
+	cpuWork       *util.CpuWork
+
+	// SpanAnnotations are added to the client span of the call.
+	util.SpanAnnotations `mapstructure:",squash"`
 }
 
 func (r *Route) GetDelayDuration() *util.Delay {
@@ -95,9 +224,38 @@ func (r *Route) GetDelayDuration() *util.Delay {
 	return r.delayDuration
 }
 
+func (r *Route) GetCpuWork() *util.CpuWork {
+	if r.cpuWork == nil {
+		r.mutex.Lock()
+		r.cpuWork = util.ParseCpuWork(r.CpuWork)
+		r.mutex.Unlock()
+	}
+	return r.cpuWork
+}
+
+type Recorder struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	File        string `mapstructure:"file" validate:"required_with=Enabled"`
+	MaxBodySize string `mapstructure:"maxBodySize"`
+}
+
+// Grpc serves endpoints over gRPC on its own port. The uri of an endpoint is the full method name
+// "package.Service/Method", requests and responses are google.protobuf.Struct messages.
+type Grpc struct {
+	Enabled    bool       `mapstructure:"enabled"`
+	Port       int        `mapstructure:"port" validate:"required_with=Enabled"`
+	Reflection bool       `mapstructure:"reflection"`
+	Endpoints  []Endpoint `mapstructure:"endpoints" validate:"dive"`
+}
+
 type OtelConfig struct {
-	Trace   TraceConfig   `mapstructure:"trace" `
-	Metrics MetricsConfig `mapstructure:"metrics" `
+	Trace      TraceConfig      `mapstructure:"trace" `
+	Metrics    MetricsConfig    `mapstructure:"metrics" `
+	Logs       LogsConfig       `mapstructure:"logs" `
+	Prometheus PrometheusConfig `mapstructure:"prometheus" `
+	Resource   ResourceConfig   `mapstructure:"resource" `
+	// Propagators extract and inject the trace context: tracecontext, baggage, b3, b3multi, jaeger or none.
+	Propagators []string `mapstructure:"propagators" validate:"dive,oneof=tracecontext baggage b3 b3multi jaeger none"`
 }
 
 type TraceConfig struct {
@@ -108,6 +266,63 @@ type TraceConfig struct {
 	GrpcEndpoint    string `mapstructure:"grpc-endpoint" `
 	GrpcEndpointURL string `mapstructure:"grpc-endpoint-url" `
 	Insecure        bool   `mapstructure:"insecure" `
+
+	// BatchSize is the maximum number of spans per export.
+	BatchSize      int           `mapstructure:"batch-size" validate:"gte=0"`
+	Sampler        SamplerConfig `mapstructure:"sampler" `
+	LocalExporters `mapstructure:",squash"`
+	ExporterConfig `mapstructure:",squash"`
+}
+
+// ResourceConfig describes the service in traces, metrics and logs. OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES
+// take precedence over the configured values.
+type ResourceConfig struct {
+	// ServiceName defaults to the serviceName of the configuration.
+	ServiceName      string            `mapstructure:"serviceName" `
+	ServiceVersion   string            `mapstructure:"serviceVersion" `
+	ServiceNamespace string            `mapstructure:"serviceNamespace" `
+	Environment      string            `mapstructure:"environment" `
+	Detectors        []string          `mapstructure:"detectors" validate:"dive,oneof=host os process container"`
+	Attributes       map[string]string `mapstructure:"attributes" `
+}
+
+// SamplerConfig selects the trace sampler, the types follow OTEL_TRACES_SAMPLER plus "rules".
+type SamplerConfig struct {
+	Type string `mapstructure:"type" validate:"oneof=always_on always_off traceidratio parentbased_always_on parentbased_always_off parentbased_traceidratio rules"`
+	// Arg is the sampling ratio of the traceidratio and rules samplers.
+	Arg   float64      `mapstructure:"arg" validate:"gte=0,lte=1"`
+	Rules SamplerRules `mapstructure:"rules" `
+}
+
+// SamplerRules keep traces with errors or slow requests at their own ratio, other traces are kept at Arg.
+type SamplerRules struct {
+	ErrorRatio    float64 `mapstructure:"errorRatio" validate:"gte=0,lte=1"`
+	SlowThreshold string  `mapstructure:"slowThreshold" `
+	SlowRatio     float64 `mapstructure:"slowRatio" validate:"gte=0,lte=1"`
+	// MaxTraces limits the traces buffered until their local root span ends.
+	MaxTraces int `mapstructure:"maxTraces" validate:"gte=0"`
+	// MaxSpans limits the spans buffered per trace, MaxTraceAge how long a trace is buffered.
+	MaxSpans    int    `mapstructure:"maxSpans" validate:"gte=0"`
+	MaxTraceAge string `mapstructure:"maxTraceAge" `
+}
+
+// ExporterConfig secures and tunes the http and grpc exporters. Headers are also read from
+// OTEL_EXPORTER_OTLP_HEADERS and the signal specific variables by the exporters.
+type ExporterConfig struct {
+	// Certificate is the CA file to verify the collector, the client certificate and key enable mTLS.
+	Certificate       string            `mapstructure:"certificate" `
+	ClientCertificate string            `mapstructure:"client-certificate" `
+	ClientKey         string            `mapstructure:"client-key" `
+	Headers           map[string]string `mapstructure:"headers" `
+	Compression       string            `mapstructure:"compression" validate:"omitempty,oneof=gzip none"`
+	// Timeout is a duration or milliseconds like OTEL_EXPORTER_OTLP_TIMEOUT.
+	Timeout string `mapstructure:"timeout" `
+}
+
+// LocalExporters write OTLP-JSON to stdout or a file, they can be combined with an http or grpc endpoint.
+type LocalExporters struct {
+	Stdout bool   `mapstructure:"stdout" `
+	File   string `mapstructure:"file" `
 }
 
 type MetricsConfig struct {
@@ -117,6 +332,38 @@ type MetricsConfig struct {
 	GrpcEndpoint    string `mapstructure:"grpc-endpoint" `
 	GrpcEndpointURL string `mapstructure:"grpc-endpoint-url" `
 	Insecure        bool   `mapstructure:"insecure" `
+
+	LocalExporters `mapstructure:",squash"`
+	ExporterConfig `mapstructure:",squash"`
+	// ExportInterval is a duration or milliseconds like OTEL_METRIC_EXPORT_INTERVAL.
+	ExportInterval string `mapstructure:"export-interval" `
+
+	// Runtime adds the Go runtime metrics: heap, garbage collection and goroutines.
+	Runtime bool `mapstructure:"runtime" `
+	// Host adds the process and host metrics: cpu, memory, network, resident memory and open file descriptors.
+	Host bool `mapstructure:"host" `
+}
+
+type LogsConfig struct {
+	Enabled         bool   `mapstructure:"enabled" `
+	HttpEndpoint    string `mapstructure:"http-endpoint" `
+	HttpEndpointURL string `mapstructure:"http-endpoint-url" `
+	GrpcEndpoint    string `mapstructure:"grpc-endpoint" `
+	GrpcEndpointURL string `mapstructure:"grpc-endpoint-url" `
+	Insecure        bool   `mapstructure:"insecure" `
+
+	LocalExporters `mapstructure:",squash"`
+	ExporterConfig `mapstructure:",squash"`
+	// BatchSize is the maximum number of log records per export.
+	BatchSize int `mapstructure:"batch-size" validate:"gte=0"`
+}
+
+// PrometheusConfig exposes metrics for scraping, independent of pushing them with OTLP.
+// When Port equals the service port the metrics are served by the service itself.
+type PrometheusConfig struct {
+	Enabled bool   `mapstructure:"enabled" `
+	Path    string `mapstructure:"path" `
+	Port    int    `mapstructure:"port" `
 }
 
 func GetConfig(configFile string) (*Configuration, error) {
@@ -129,16 +376,59 @@ func GetConfig(configFile string) (*Configuration, error) {
 	v.SetDefault("address", "0.0.0.0")
 	v.SetDefault("port", 8080)
 	v.SetDefault("logLevel", "info")
+	v.SetDefault("healthUri", "/health")
 	v.SetDefault("logging.logOnCall", 1)
+	v.SetDefault("traceLog.enabled", true)
+	v.SetDefault("traceLog.convention", "w3c")
+	v.SetDefault("certificate.exitOnExpired", true)
+	v.SetDefault("certificate.checkInterval", "1m")
+	v.SetDefault("certificate.warnBefore", []string{"720h", "168h", "24h", "1h"})
+	v.SetDefault("certificate.failOnExpiry", "none")
+	v.SetDefault("crash.enabled", false)
+	v.SetDefault("crash.mode", "exit")
+	v.SetDefault("crash.exitCode", 1)
+	v.SetDefault("crash.message", "simulated crash")
+	v.SetDefault("crash.stateFile", filepath.Join(os.TempDir(), "sim-crash-state"))
 	v.SetDefault("stressng.enabled", false)
 	v.SetDefault("stressng.delay", "")
 	v.SetDefault("stressng.args", []string{"-c", "0", "-l", "10"})
+	v.SetDefault("stressng.outputLevel", "debug")
+	v.SetDefault("cpustress.enabled", false)
+	v.SetDefault("cpustress.delay", "")
+	v.SetDefault("cpustress.backend", "native")
+	v.SetDefault("cpustress.load", "10%")
 	v.SetDefault("memstress.enabled", false)
 	v.SetDefault("memstress.delay", "")
+	v.SetDefault("memstress.pattern", "linear")
 	v.SetDefault("memstress.memsize", "10%")
 	v.SetDefault("memstress.growthtime", "10s")
+	v.SetDefault("resources.basis", "auto")
+	v.SetDefault("recorder.enabled", false)
+	v.SetDefault("recorder.file", "requests.jsonl")
+	v.SetDefault("recorder.maxBodySize", "64 KB")
+	v.SetDefault("grpc.enabled", false)
+	v.SetDefault("grpc.port", 9090)
+	v.SetDefault("grpc.reflection", true)
 	v.SetDefault("otel.trace.enabled", false)
+	v.SetDefault("otel.trace.sampler.type", "parentbased_always_on")
+	v.SetDefault("otel.trace.sampler.arg", 1.0)
+	v.SetDefault("otel.trace.sampler.rules.errorRatio", 1.0)
+	v.SetDefault("otel.trace.sampler.rules.slowRatio", 1.0)
+	v.SetDefault("otel.trace.sampler.rules.maxTraces", 10000)
+	v.SetDefault("otel.trace.sampler.rules.maxSpans", 1000)
+	v.SetDefault("otel.trace.sampler.rules.maxTraceAge", "1m")
 	v.SetDefault("otel.metrics.enabled", false)
+	v.SetDefault("otel.metrics.runtime", false)
+	v.SetDefault("otel.metrics.host", false)
+	v.SetDefault("otel.logs.enabled", false)
+	v.SetDefault("otel.prometheus.enabled", false)
+	v.SetDefault("otel.prometheus.path", "/metrics")
+	v.SetDefault("otel.prometheus.port", 9464)
+	v.SetDefault("otel.propagators", []string{"tracecontext", "baggage"})
+	v.SetDefault("otel.resource.detectors", []string{"host", "os", "process", "container"})
+
+	v.BindEnv("resources.memoryRequest", "MEMORY_REQUEST") //nolint:errcheck
+	v.BindEnv("resources.cpuRequest", "CPU_REQUEST")       //nolint:errcheck
 
 	v.BindEnv("otel.trace.enabled", "OTEL_EXPORTER_OTLP_TRACES_ENABLED")                //nolint:errcheck
 	v.BindEnv("otel.trace.tracer-name", "OTEL_EXPORTER_OTLP_TRACES_TRACER_NAME")        //nolint:errcheck
@@ -147,6 +437,15 @@ func GetConfig(configFile string) (*Configuration, error) {
 	v.BindEnv("otel.trace.grpc-endpoint", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")         //nolint:errcheck
 	v.BindEnv("otel.trace.grpc-endpoint-url", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT_URL") //nolint:errcheck
 	v.BindEnv("otel.trace.insecure", "OTEL_EXPORTER_OTLP_TRACES_INSECURE")              //nolint:errcheck
+	v.BindEnv("otel.trace.sampler.type", "OTEL_TRACES_SAMPLER")                         //nolint:errcheck
+	v.BindEnv("otel.trace.sampler.arg", "OTEL_TRACES_SAMPLER_ARG")                      //nolint:errcheck
+
+	v.BindEnv("otel.trace.certificate", "OTEL_EXPORTER_OTLP_TRACES_CERTIFICATE")               //nolint:errcheck
+	v.BindEnv("otel.trace.client-certificate", "OTEL_EXPORTER_OTLP_TRACES_CLIENT_CERTIFICATE") //nolint:errcheck
+	v.BindEnv("otel.trace.client-key", "OTEL_EXPORTER_OTLP_TRACES_CLIENT_KEY")                 //nolint:errcheck
+	v.BindEnv("otel.trace.compression", "OTEL_EXPORTER_OTLP_TRACES_COMPRESSION")               //nolint:errcheck
+	v.BindEnv("otel.trace.timeout", "OTEL_EXPORTER_OTLP_TRACES_TIMEOUT")                       //nolint:errcheck
+	v.BindEnv("otel.trace.batch-size", "OTEL_BSP_MAX_EXPORT_BATCH_SIZE")                       //nolint:errcheck
 
 	v.BindEnv("otel.metrics.enabled", "OTEL_EXPORTER_OTLP_METRICS_ENABLED")                //nolint:errcheck
 	v.BindEnv("otel.metrics.http-endpoint", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT")         //nolint:errcheck
@@ -155,6 +454,29 @@ func GetConfig(configFile string) (*Configuration, error) {
 	v.BindEnv("otel.metrics.grpc-endpoint-url", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT_URL") //nolint:errcheck
 	v.BindEnv("otel.metrics.insecure", "OTEL_EXPORTER_OTLP_METRICS_INSECURE")              //nolint:errcheck
 
+	v.BindEnv("otel.metrics.certificate", "OTEL_EXPORTER_OTLP_METRICS_CERTIFICATE")               //nolint:errcheck
+	v.BindEnv("otel.metrics.client-certificate", "OTEL_EXPORTER_OTLP_METRICS_CLIENT_CERTIFICATE") //nolint:errcheck
+	v.BindEnv("otel.metrics.client-key", "OTEL_EXPORTER_OTLP_METRICS_CLIENT_KEY")                 //nolint:errcheck
+	v.BindEnv("otel.metrics.compression", "OTEL_EXPORTER_OTLP_METRICS_COMPRESSION")               //nolint:errcheck
+	v.BindEnv("otel.metrics.timeout", "OTEL_EXPORTER_OTLP_METRICS_TIMEOUT")                       //nolint:errcheck
+	v.BindEnv("otel.metrics.export-interval", "OTEL_METRIC_EXPORT_INTERVAL")                      //nolint:errcheck
+
+	v.BindEnv("otel.logs.enabled", "OTEL_EXPORTER_OTLP_LOGS_ENABLED")                //nolint:errcheck
+	v.BindEnv("otel.logs.http-endpoint", "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT")         //nolint:errcheck
+	v.BindEnv("otel.logs.http-endpoint-url", "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT_URL") //nolint:errcheck
+	v.BindEnv("otel.logs.grpc-endpoint", "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT")         //nolint:errcheck
+	v.BindEnv("otel.logs.grpc-endpoint-url", "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT_URL") //nolint:errcheck
+	v.BindEnv("otel.logs.insecure", "OTEL_EXPORTER_OTLP_LOGS_INSECURE")              //nolint:errcheck
+
+	v.BindEnv("otel.logs.certificate", "OTEL_EXPORTER_OTLP_LOGS_CERTIFICATE")               //nolint:errcheck
+	v.BindEnv("otel.logs.client-certificate", "OTEL_EXPORTER_OTLP_LOGS_CLIENT_CERTIFICATE") //nolint:errcheck
+	v.BindEnv("otel.logs.client-key", "OTEL_EXPORTER_OTLP_LOGS_CLIENT_KEY")                 //nolint:errcheck
+	v.BindEnv("otel.logs.compression", "OTEL_EXPORTER_OTLP_LOGS_COMPRESSION")               //nolint:errcheck
+	v.BindEnv("otel.logs.timeout", "OTEL_EXPORTER_OTLP_LOGS_TIMEOUT")                       //nolint:errcheck
+	v.BindEnv("otel.logs.batch-size", "OTEL_BLRP_MAX_EXPORT_BATCH_SIZE")                    //nolint:errcheck
+
+	v.BindEnv("otel.propagators", "OTEL_PROPAGATORS") //nolint:errcheck
+
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
 	if configFile != "" {
@@ -175,6 +497,11 @@ func GetConfig(configFile string) (*Configuration, error) {
 		slog.Error("Error unmarshalling config", slog.Any("err", err))
 		return nil, err
 	}
+	if c.OpenTelemetry.Resource.ServiceName == "" {
+		c.OpenTelemetry.Resource.ServiceName = c.ServiceName
+	}
+	c.OpenTelemetry.Propagators = normalizeNames(c.OpenTelemetry.Propagators)
+	c.OpenTelemetry.Resource.Detectors = normalizeNames(c.OpenTelemetry.Resource.Detectors)
 
 	validate := validator.New(validator.WithRequiredStructEnabled())
 	err := validate.Struct(c)
@@ -189,18 +516,51 @@ func GetConfig(configFile string) (*Configuration, error) {
 	return c, nil
 }
 
+// normalizeNames trims and lowercases names of lists like OTEL_PROPAGATORS="tracecontext, baggage" and drops empty ones.
+func normalizeNames(names []string) []string {
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized
+}
+
 func (c OtelConfig) Validate() error {
-	if c.Trace.Enabled && countSet(c.Trace.HttpEndpointURL, c.Trace.GrpcEndpointURL, c.Trace.HttpEndpoint, c.Trace.GrpcEndpoint) != 1 {
-		return fmt.Errorf("exactly one http or grpc endpoint is required when opentelemetry tracing is enabled")
+	if c.Trace.Enabled {
+		if err := validateExporters("tracing", c.Trace.LocalExporters, c.Trace.HttpEndpointURL, c.Trace.GrpcEndpointURL, c.Trace.HttpEndpoint, c.Trace.GrpcEndpoint); err != nil {
+			return err
+		}
 	}
 
-	if c.Metrics.Enabled && countSet(c.Metrics.HttpEndpointURL, c.Metrics.GrpcEndpointURL, c.Metrics.HttpEndpoint, c.Metrics.GrpcEndpoint) != 1 {
-		return fmt.Errorf("exactly one http or grpc endpoint is required when opentelemetry metrics is enabled")
+	if c.Metrics.Enabled {
+		if err := validateExporters("metrics", c.Metrics.LocalExporters, c.Metrics.HttpEndpointURL, c.Metrics.GrpcEndpointURL, c.Metrics.HttpEndpoint, c.Metrics.GrpcEndpoint); err != nil {
+			return err
+		}
+	}
+
+	if c.Logs.Enabled {
+		if err := validateExporters("logs", c.Logs.LocalExporters, c.Logs.HttpEndpointURL, c.Logs.GrpcEndpointURL, c.Logs.HttpEndpoint, c.Logs.GrpcEndpoint); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// validateExporters requires at least one exporter, at most one of them sending to an endpoint.
+func validateExporters(signal string, local LocalExporters, endpoints ...string) error {
+	count := countSet(endpoints...)
+	if count > 1 {
+		return fmt.Errorf("at most one http or grpc endpoint can be set for opentelemetry %s", signal)
+	}
+	if count == 0 && !local.Stdout && local.File == "" {
+		return fmt.Errorf("an http or grpc endpoint, stdout or file is required when opentelemetry %s is enabled", signal)
+	}
+	return nil
+}
+
 func countSet(s ...string) int {
 	count := 0
 	for _, v := range s {
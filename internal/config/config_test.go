@@ -0,0 +1,58 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+	return file
+}
+
+func TestSpanValidation(t *testing.T) {
+	_, err := GetConfig(writeConfig(t, `
+[[endpoints]]
+uri = "/list"
+[[endpoints.spans]]
+name = "load"
+[[endpoints.spans.spans]]
+name = "db.query"
+preset = "db"
+errorRate = 0.1
+`))
+	require.NoError(t, err)
+
+	for _, span := range []string{
+		`name = "cache.get"
+preset = "cahce"`,
+		`name = "work"
+kind = "sever"`,
+		`name = "work"
+errorRate = 2`,
+		`preset = "db"`,
+	} {
+		_, err := GetConfig(writeConfig(t, `
+[[endpoints]]
+uri = "/list"
+[[endpoints.spans]]
+name = "load"
+[[endpoints.spans.spans]]
+`+span))
+		require.Error(t, err, span)
+	}
+}
+
+func TestPropagatorsFromEnv(t *testing.T) {
+	t.Setenv("OTEL_PROPAGATORS", "tracecontext, Baggage,b3multi")
+	c, err := GetConfig("")
+	require.NoError(t, err)
+	require.Equal(t, []string{"tracecontext", "baggage", "b3multi"}, c.OpenTelemetry.Propagators)
+
+	t.Setenv("OTEL_PROPAGATORS", "tracecontext, xray")
+	_, err = GetConfig("")
+	require.Error(t, err)
+}
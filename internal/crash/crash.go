@@ -0,0 +1,68 @@
+package crash
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	ModeExit     = "exit"
+	ModePanic    = "panic"
+	ModeSegfault = "segfault"
+)
+
+// Now crashes the process. Panics are raised on a new goroutine so they are not recovered by the http server.
+func Now(mode string, exitCode int, reason string) {
+	switch mode {
+	case ModePanic:
+		go processRequest(reason)
+		select {}
+	case ModeSegfault:
+		slog.Error("simulated crash", "mode", mode, "reason", reason)
+		// a SIGSEGV that is not caused by a memory fault makes the go runtime abort with a goroutine dump
+		_ = syscall.Kill(os.Getpid(), syscall.SIGSEGV)
+		select {}
+	default:
+		slog.Error("simulated crash", "mode", mode, "reason", reason, "exitCode", exitCode)
+		os.Exit(exitCode)
+	}
+}
+
+// RecordStart increments the number of starts kept in the state file and returns the new count.
+// Keep the state file on a volume that survives container restarts, like an emptyDir.
+func RecordStart(stateFile string) (int, error) {
+	starts := 0
+	content, err := os.ReadFile(stateFile)
+	if err == nil {
+		starts, err = strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			return 0, fmt.Errorf("invalid crash state file %s: %w", stateFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+	starts++
+	if err = os.MkdirAll(filepath.Dir(stateFile), 0755); err != nil {
+		return 0, err
+	}
+	return starts, os.WriteFile(stateFile, []byte(strconv.Itoa(starts)), 0644)
+}
+
+// The call chain below gives simulated panics a believable stack trace.
+
+func processRequest(reason string) {
+	resolveDependencies(reason)
+}
+
+func resolveDependencies(reason string) {
+	loadConfiguration(reason)
+}
+
+func loadConfiguration(reason string) {
+	panic(fmt.Errorf("simulated crash: %s", reason))
+}
@@ -0,0 +1,33 @@
+package crash
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordStart(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state", "crash-state")
+	for i := 1; i <= 3; i++ {
+		starts, err := RecordStart(stateFile)
+		require.NoError(t, err)
+		require.Equal(t, i, starts)
+	}
+}
+
+func TestNow(t *testing.T) {
+	if mode := os.Getenv("CRASH_MODE"); mode != "" {
+		Now(mode, 3, "test")
+		return
+	}
+	for mode, exitCode := range map[string]int{ModeExit: 3, ModePanic: 2, ModeSegfault: 2} {
+		cmd := exec.Command(os.Args[0], "-test.run=TestNow")
+		cmd.Env = append(os.Environ(), "CRASH_MODE="+mode)
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		require.ErrorAs(t, err, &exitErr, mode)
+		require.Equal(t, exitCode, exitErr.ExitCode(), mode)
+	}
+}
@@ -0,0 +1,109 @@
+package load
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// Latencies are recorded in microseconds into log-linear buckets in the style of HdrHistogram: values below
+// subBucketCount are exact, above that every power of two is split into subBucketHalf linear buckets, which
+// keeps the relative error of reported percentiles below 1/subBucketHalf (~1.6%) for any latency.
+const (
+	subBucketBits  = 7
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+	bucketCount    = (64-subBucketBits+1)*subBucketHalf + subBucketHalf
+)
+
+type Histogram struct {
+	counts [bucketCount]uint64
+	count  uint64
+	sum    uint64
+	min    uint64
+	max    uint64
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{min: math.MaxUint64}
+}
+
+func (h *Histogram) Record(d time.Duration) {
+	value := uint64(max(0, d.Microseconds()))
+	h.counts[bucketIndex(value)]++
+	h.count++
+	h.sum += value
+	h.min = min(h.min, value)
+	h.max = max(h.max, value)
+}
+
+// Merge adds all values recorded by other.
+func (h *Histogram) Merge(other *Histogram) {
+	for i, count := range other.counts {
+		h.counts[i] += count
+	}
+	h.count += other.count
+	h.sum += other.sum
+	h.min = min(h.min, other.min)
+	h.max = max(h.max, other.max)
+}
+
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+func (h *Histogram) Min() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return micros(h.min)
+}
+
+func (h *Histogram) Max() time.Duration {
+	return micros(h.max)
+}
+
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return micros(h.sum / h.count)
+}
+
+// Percentile returns the highest latency equivalent to the value at percentile p (0-100).
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(h.count)))
+	rank = max(1, min(rank, h.count))
+	var seen uint64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			return micros(min(highestEquivalentValue(i), h.max))
+		}
+	}
+	return micros(h.max)
+}
+
+func bucketIndex(value uint64) int {
+	if value < subBucketCount {
+		return int(value)
+	}
+	shift := bits.Len64(value) - subBucketBits
+	return shift*subBucketHalf + int(value>>shift)
+}
+
+func highestEquivalentValue(index int) uint64 {
+	if index < subBucketCount {
+		return uint64(index)
+	}
+	shift := index/subBucketHalf - 1
+	subBucket := uint64(index - shift*subBucketHalf)
+	return (subBucket+1)<<shift - 1
+}
+
+func micros(value uint64) time.Duration {
+	return time.Duration(value) * time.Microsecond
+}
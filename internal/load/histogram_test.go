@@ -0,0 +1,49 @@
+package load
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentiles(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	require.Equal(t, uint64(10000), h.Count())
+	require.Equal(t, time.Millisecond, h.Min())
+	require.Equal(t, 10*time.Second, h.Max())
+	require.InDelta(t, float64(5*time.Second), float64(h.Mean()), float64(time.Millisecond))
+
+	for _, p := range []float64{50, 90, 99, 99.9} {
+		expected := time.Duration(p*100) * time.Millisecond
+		require.InEpsilon(t, float64(expected), float64(h.Percentile(p)), 0.02, "p%v", p)
+	}
+	require.Equal(t, 10*time.Second, h.Percentile(100))
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	for _, value := range []uint64{0, 1, 127, 128, 129, 255, 256, 1000, 123456789, 1 << 62} {
+		index := bucketIndex(value)
+		require.Less(t, index, bucketCount)
+		require.GreaterOrEqual(t, highestEquivalentValue(index), value)
+		if index > 0 {
+			require.Less(t, highestEquivalentValue(index-1), value)
+		}
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	a.Record(time.Millisecond)
+	b.Record(3 * time.Millisecond)
+	empty := NewHistogram()
+	a.Merge(b)
+	a.Merge(empty)
+	require.Equal(t, uint64(2), a.Count())
+	require.Equal(t, time.Millisecond, a.Min())
+	require.Equal(t, 3*time.Millisecond, a.Max())
+	require.Equal(t, 2*time.Millisecond, a.Mean())
+	require.Equal(t, time.Duration(0), empty.Percentile(99))
+}
@@ -0,0 +1,349 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/otel"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ModeRate        = "rps"
+	ModeConcurrency = "concurrency"
+
+	tickInterval = 10 * time.Millisecond
+)
+
+// Target is a request in the mix, picked proportionally to its weight.
+type Target struct {
+	Method string
+	URL    string
+	Weight int
+}
+
+// Stage moves the request rate (or number of concurrent workers) linearly from the previous stage's value to
+// Value over Duration. The first stage starts at zero, a zero Duration jumps to Value immediately which
+// allows modelling spikes.
+type Stage struct {
+	Duration time.Duration
+	Value    float64
+}
+
+type Options struct {
+	Targets        []Target
+	Mode           string
+	Stages         []Stage
+	Headers        http.Header
+	Timeout        time.Duration
+	ReportInterval time.Duration
+	Out            io.Writer
+}
+
+// ParseTarget parses "[METHOD ]URL[ WEIGHT]", e.g. "POST localhost:8080/orders 3". The scheme defaults to http.
+func ParseTarget(target string) (Target, error) {
+	fields := strings.Fields(target)
+	t := Target{Method: http.MethodGet, Weight: 1}
+	if len(fields) > 1 && strings.ToUpper(fields[0]) == fields[0] && !strings.Contains(fields[0], "/") {
+		t.Method = fields[0]
+		fields = fields[1:]
+	}
+	if len(fields) == 2 {
+		weight, err := strconv.Atoi(fields[1])
+		if err != nil || weight <= 0 {
+			return t, fmt.Errorf("invalid weight in target %q", target)
+		}
+		t.Weight = weight
+		fields = fields[:1]
+	}
+	if len(fields) != 1 {
+		return t, fmt.Errorf("invalid target %q, expected [METHOD ]URL[ WEIGHT]", target)
+	}
+	t.URL = fields[0]
+	if !strings.Contains(t.URL, "://") {
+		t.URL = "http://" + t.URL
+	}
+	return t, nil
+}
+
+// ParseStage parses "DURATION:VALUE", e.g. "30s:100".
+func ParseStage(stage string) (Stage, error) {
+	duration, value, ok := strings.Cut(stage, ":")
+	if !ok {
+		return Stage{}, fmt.Errorf("invalid stage %q, expected DURATION:VALUE", stage)
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil || d < 0 {
+		return Stage{}, fmt.Errorf("invalid duration in stage %q", stage)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v < 0 {
+		return Stage{}, fmt.Errorf("invalid value in stage %q", stage)
+	}
+	return Stage{Duration: d, Value: v}, nil
+}
+
+type schedule []Stage
+
+func (s schedule) duration() time.Duration {
+	var total time.Duration
+	for _, stage := range s {
+		total += stage.Duration
+	}
+	return total
+}
+
+// valueAt returns the rate or concurrency at elapsed.
+func (s schedule) valueAt(elapsed time.Duration) float64 {
+	from := 0.0
+	for _, stage := range s {
+		if elapsed < stage.Duration {
+			return from + (stage.Value-from)*float64(elapsed)/float64(stage.Duration)
+		}
+		elapsed -= stage.Duration
+		from = stage.Value
+	}
+	return from
+}
+
+// requestsUntil integrates the rate over time, returning how many requests are due at elapsed.
+func (s schedule) requestsUntil(elapsed time.Duration) float64 {
+	from, total := 0.0, 0.0
+	for _, stage := range s {
+		d := min(elapsed, stage.Duration)
+		if stage.Duration > 0 {
+			t := d.Seconds()
+			total += from*t + (stage.Value-from)*t*t/(2*stage.Duration.Seconds())
+		}
+		if elapsed < stage.Duration {
+			return total
+		}
+		elapsed -= stage.Duration
+		from = stage.Value
+	}
+	return total
+}
+
+type runner struct {
+	opts     Options
+	schedule schedule
+	client   *http.Client
+	weights  int
+
+	inflight sync.WaitGroup
+	mu       sync.Mutex
+	start    time.Time
+	targets  []*Stats
+	interval *Stats
+}
+
+// Run generates load until all stages completed or ctx is done and writes a summary to opts.Out.
+func Run(ctx context.Context, opts Options) (*Summary, error) {
+	if len(opts.Targets) == 0 {
+		return nil, fmt.Errorf("at least one target is required")
+	}
+	if schedule(opts.Stages).duration() <= 0 {
+		return nil, fmt.Errorf("load duration must be larger than zero")
+	}
+	r := &runner{
+		opts:     opts,
+		schedule: opts.Stages,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+		interval: newStats(),
+	}
+	for range opts.Targets {
+		r.targets = append(r.targets, newStats())
+	}
+	for _, target := range opts.Targets {
+		r.weights += target.Weight
+	}
+
+	// the schedule ends after the last stage, requests in flight are only interrupted when ctx is done
+	scheduleCtx, cancel := context.WithTimeout(ctx, r.schedule.duration())
+	defer cancel()
+	r.start = time.Now()
+	done := make(chan struct{})
+	go r.report(done)
+	if opts.Mode == ModeConcurrency {
+		r.runWorkers(scheduleCtx, ctx)
+	} else {
+		r.runRate(scheduleCtx, ctx)
+	}
+	r.inflight.Wait()
+	close(done)
+
+	summary := r.summary()
+	summary.Write(opts.Out)
+	return summary, nil
+}
+
+// runRate is an open model, requests are started on schedule regardless of how long earlier requests take.
+func (r *runner) runRate(scheduleCtx, ctx context.Context) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	sent := 0
+	for {
+		select {
+		case <-scheduleCtx.Done():
+			return
+		case <-ticker.C:
+			due := int(r.schedule.requestsUntil(time.Since(r.start)))
+			for ; sent < due; sent++ {
+				r.inflight.Add(1)
+				go func() {
+					defer r.inflight.Done()
+					r.do(ctx)
+				}()
+			}
+		}
+	}
+}
+
+// runWorkers is a closed model, every worker sends its next request once the previous one completed.
+func (r *runner) runWorkers(scheduleCtx, ctx context.Context) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	var workers []chan struct{}
+	for {
+		select {
+		case <-scheduleCtx.Done():
+			for _, stop := range workers {
+				close(stop)
+			}
+			return
+		case <-ticker.C:
+			target := int(r.schedule.valueAt(time.Since(r.start)) + 0.5)
+			for len(workers) < target {
+				stop := make(chan struct{})
+				workers = append(workers, stop)
+				r.inflight.Add(1)
+				go r.worker(ctx, stop)
+			}
+			for len(workers) > target {
+				close(workers[len(workers)-1])
+				workers = workers[:len(workers)-1]
+			}
+		}
+	}
+}
+
+func (r *runner) worker(ctx context.Context, stop <-chan struct{}) {
+	defer r.inflight.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		default:
+			r.do(ctx)
+		}
+	}
+}
+
+func (r *runner) do(ctx context.Context) {
+	index := r.pick()
+	target := r.opts.Targets[index]
+	ctx, span := otel.Tracer.Start(ctx, fmt.Sprintf("load %s %s", target.Method, target.URL),
+		trace.WithNewRoot(),
+		trace.WithAttributes(attribute.String("sim.load.target", target.URL)),
+	)
+	defer span.End()
+
+	start := time.Now()
+	outcome, err := r.send(ctx, target)
+	latency := time.Since(start)
+	if err != nil && ctx.Err() != nil {
+		// the run was interrupted, the request did not complete
+		return
+	}
+	failed := err != nil || outcome >= http.StatusBadRequest
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if failed {
+		span.SetStatus(codes.Error, http.StatusText(outcome))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.targets[index].record(latency, outcome, failed)
+	r.interval.record(latency, outcome, failed)
+}
+
+// send returns the status code of the response.
+func (r *runner) send(ctx context.Context, target Target) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, target.Method, target.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	for key, values := range r.opts.Headers {
+		req.Header[key] = values
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+func (r *runner) pick() int {
+	if len(r.opts.Targets) == 1 {
+		return 0
+	}
+	n := rand.IntN(r.weights)
+	for i, target := range r.opts.Targets {
+		if n < target.Weight {
+			return i
+		}
+		n -= target.Weight
+	}
+	return len(r.opts.Targets) - 1
+}
+
+func (r *runner) report(done <-chan struct{}) {
+	if r.opts.ReportInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(r.opts.ReportInterval)
+	defer ticker.Stop()
+	last := r.start
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			r.mu.Lock()
+			interval := r.interval
+			r.interval = newStats()
+			r.mu.Unlock()
+			interval.writeProgress(r.opts.Out, now.Sub(r.start), now.Sub(last))
+			last = now
+		}
+	}
+}
+
+func (r *runner) summary() *Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	summary := &Summary{Elapsed: time.Since(r.start), Total: newStats()}
+	for i, target := range r.opts.Targets {
+		summary.Targets = append(summary.Targets, TargetSummary{Target: target, Stats: r.targets[i]})
+		summary.Total.merge(r.targets[i])
+	}
+	return summary
+}
@@ -0,0 +1,82 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/ravan/microservice-sim/internal/otel"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	target, err := ParseTarget("localhost:8080/orders")
+	require.NoError(t, err)
+	require.Equal(t, Target{Method: "GET", URL: "http://localhost:8080/orders", Weight: 1}, target)
+
+	target, err = ParseTarget("POST https://shop/orders 3")
+	require.NoError(t, err)
+	require.Equal(t, Target{Method: "POST", URL: "https://shop/orders", Weight: 3}, target)
+
+	_, err = ParseTarget("GET shop/orders heavy")
+	require.Error(t, err)
+	_, err = ParseTarget("")
+	require.Error(t, err)
+}
+
+func TestSchedule(t *testing.T) {
+	var stages schedule
+	for _, stage := range []string{"10s:100", "10s:100", "0s:500", "2s:500", "0s:0"} {
+		s, err := ParseStage(stage)
+		require.NoError(t, err)
+		stages = append(stages, s)
+	}
+	require.Equal(t, 22*time.Second, stages.duration())
+	require.InDelta(t, 50, stages.valueAt(5*time.Second), 0.001)
+	require.InDelta(t, 100, stages.valueAt(15*time.Second), 0.001)
+	require.InDelta(t, 500, stages.valueAt(21*time.Second), 0.001)
+	// ramp to 100 rps over 10s sends 500 requests, holding sends 100 per second
+	require.InDelta(t, 500, stages.requestsUntil(10*time.Second), 0.001)
+	require.InDelta(t, 1000, stages.requestsUntil(15*time.Second), 0.001)
+	require.InDelta(t, 2500, stages.requestsUntil(22*time.Second), 0.001)
+
+	_, err := ParseStage("10s")
+	require.Error(t, err)
+	_, err = ParseStage("fast:10")
+	require.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	otel.NewTracer(config.OtelConfig{})
+	var headers []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Get("X-Scenario"))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	summary, err := Run(context.Background(), Options{
+		Targets: []Target{
+			{Method: "GET", URL: srv.URL + "/ok", Weight: 1},
+			{Method: "GET", URL: srv.URL + "/fail", Weight: 1},
+		},
+		Mode:    ModeConcurrency,
+		Stages:  []Stage{{Duration: 0, Value: 1}, {Duration: 200 * time.Millisecond, Value: 1}},
+		Headers: http.Header{"X-Scenario": []string{"load"}},
+		Timeout: time.Second,
+		Out:     &out,
+	})
+	require.NoError(t, err)
+	require.Greater(t, summary.Total.Requests, uint64(1))
+	require.Equal(t, summary.Targets[1].Stats.Requests, summary.Total.Errors)
+	require.Equal(t, summary.Total.Errors, summary.Total.Outcomes[http.StatusServiceUnavailable])
+	require.Equal(t, "load", headers[0])
+	require.Contains(t, out.String(), "P99.9")
+	require.Contains(t, out.String(), "503=")
+}
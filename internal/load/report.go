@@ -0,0 +1,132 @@
+package load
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var percentiles = []float64{50, 90, 95, 99, 99.9}
+
+// Stats aggregates the outcome of requests. Outcomes are counted by status code, 0 for requests without response.
+type Stats struct {
+	Latency  *Histogram
+	Requests uint64
+	Errors   uint64
+	Outcomes map[int]uint64
+}
+
+type TargetSummary struct {
+	Target Target
+	Stats  *Stats
+}
+
+type Summary struct {
+	Elapsed time.Duration
+	Targets []TargetSummary
+	Total   *Stats
+}
+
+func newStats() *Stats {
+	return &Stats{Latency: NewHistogram(), Outcomes: map[int]uint64{}}
+}
+
+func (s *Stats) record(latency time.Duration, outcome int, failed bool) {
+	s.Latency.Record(latency)
+	s.Requests++
+	if failed {
+		s.Errors++
+	}
+	s.Outcomes[outcome]++
+}
+
+func (s *Stats) merge(other *Stats) {
+	s.Latency.Merge(other.Latency)
+	s.Requests += other.Requests
+	s.Errors += other.Errors
+	for outcome, count := range other.Outcomes {
+		s.Outcomes[outcome] += count
+	}
+}
+
+func (s *Stats) errorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests) * 100
+}
+
+func (s *Stats) writeProgress(out io.Writer, elapsed, interval time.Duration) {
+	fmt.Fprintf(out, "[%6s] %8.1f req/s  requests=%d  errors=%d (%.1f%%)  p50=%s  p90=%s  p99=%s  max=%s\n",
+		elapsed.Round(time.Second), float64(s.Requests)/interval.Seconds(), s.Requests, s.Errors, s.errorRate(),
+		FormatLatency(s.Latency.Percentile(50)), FormatLatency(s.Latency.Percentile(90)),
+		FormatLatency(s.Latency.Percentile(99)), FormatLatency(s.Latency.Max()))
+}
+
+func (s *Summary) Write(out io.Writer) {
+	fmt.Fprintf(out, "\n%d requests in %s (%.1f req/s), %d errors (%.1f%%)\n\n",
+		s.Total.Requests, s.Elapsed.Round(time.Millisecond), float64(s.Total.Requests)/s.Elapsed.Seconds(),
+		s.Total.Errors, s.Total.errorRate())
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := []string{"TARGET", "REQUESTS", "ERRORS", "REQ/S", "MIN", "MEAN"}
+	for _, p := range percentiles {
+		header = append(header, "P"+strconv.FormatFloat(p, 'f', -1, 64))
+	}
+	header = append(header, "MAX")
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+	for _, target := range s.Targets {
+		s.writeRow(w, fmt.Sprintf("%s %s", target.Target.Method, target.Target.URL), target.Stats)
+	}
+	if len(s.Targets) > 1 {
+		s.writeRow(w, "TOTAL", s.Total)
+	}
+	_ = w.Flush()
+
+	outcomes := make([]int, 0, len(s.Total.Outcomes))
+	for outcome := range s.Total.Outcomes {
+		outcomes = append(outcomes, outcome)
+	}
+	slices.Sort(outcomes)
+	var codes []string
+	for _, outcome := range outcomes {
+		name := strconv.Itoa(outcome)
+		if outcome == 0 {
+			name = "no response"
+		}
+		codes = append(codes, fmt.Sprintf("%s=%d", name, s.Total.Outcomes[outcome]))
+	}
+	fmt.Fprintf(out, "\nStatus codes: %s\n", strings.Join(codes, " "))
+}
+
+func (s *Summary) writeRow(w io.Writer, name string, stats *Stats) {
+	row := []string{
+		name,
+		strconv.FormatUint(stats.Requests, 10),
+		strconv.FormatUint(stats.Errors, 10),
+		strconv.FormatFloat(float64(stats.Requests)/s.Elapsed.Seconds(), 'f', 1, 64),
+		FormatLatency(stats.Latency.Min()),
+		FormatLatency(stats.Latency.Mean()),
+	}
+	for _, p := range percentiles {
+		row = append(row, FormatLatency(stats.Latency.Percentile(p)))
+	}
+	row = append(row, FormatLatency(stats.Latency.Max()))
+	fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+}
+
+// FormatLatency rounds d to a precision that keeps reports readable.
+func FormatLatency(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	case d < time.Second:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Millisecond).String()
+	}
+}
@@ -0,0 +1,133 @@
+package otel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/config"
+	"os"
+	"strconv"
+	"time"
+)
+
+const compressionGzip = "gzip"
+
+// exporterSettings are the parsed settings of an http or grpc exporter.
+type exporterSettings struct {
+	endpointURL string
+	endpoint    string
+	insecure    bool
+	tls         *tls.Config
+	headers     map[string]string
+	compression string
+	timeout     time.Duration
+}
+
+// newExporterSettings returns empty settings when no endpoint is configured.
+func newExporterSettings(endpointURL, endpoint string, insecure bool, cfg config.ExporterConfig) (exporterSettings, error) {
+	if endpointURL == "" && endpoint == "" {
+		return exporterSettings{}, nil
+	}
+	settings := exporterSettings{
+		endpointURL: endpointURL,
+		endpoint:    endpoint,
+		insecure:    insecure,
+		headers:     cfg.Headers,
+		compression: cfg.Compression,
+	}
+	if insecure && (cfg.Certificate != "" || cfg.ClientCertificate != "") {
+		return settings, fmt.Errorf("exporter can't be insecure and use a certificate")
+	}
+	var err error
+	if settings.tls, err = newTlsConfig(cfg); err != nil {
+		return settings, err
+	}
+	if settings.timeout, err = parseMillis(cfg.Timeout); err != nil {
+		return settings, fmt.Errorf("invalid exporter timeout: %w", err)
+	}
+	return settings, nil
+}
+
+// exporterOptions creates the options of one of the otlp exporters from exporterSettings.
+// noCompression is nil for the grpc exporters, which don't compress by default.
+type exporterOptions[O any] struct {
+	endpointURL   func(string) O
+	endpoint      func(string) O
+	insecure      func() O
+	tls           func(*tls.Config) O
+	headers       func(map[string]string) O
+	gzip          func() O
+	noCompression func() O
+	timeout       func(time.Duration) O
+}
+
+// build returns no options when no endpoint is configured.
+func (o exporterOptions[O]) build(s exporterSettings) []O {
+	var opts []O
+	if s.endpointURL != "" {
+		opts = append(opts, o.endpointURL(s.endpointURL))
+	} else if s.endpoint != "" {
+		opts = append(opts, o.endpoint(s.endpoint))
+	} else {
+		return nil
+	}
+	if s.insecure {
+		opts = append(opts, o.insecure())
+	}
+	if s.tls != nil {
+		opts = append(opts, o.tls(s.tls))
+	}
+	if len(s.headers) > 0 {
+		opts = append(opts, o.headers(s.headers))
+	}
+	switch s.compression {
+	case compressionGzip:
+		opts = append(opts, o.gzip())
+	case "none":
+		if o.noCompression != nil {
+			opts = append(opts, o.noCompression())
+		}
+	}
+	if s.timeout > 0 {
+		opts = append(opts, o.timeout(s.timeout))
+	}
+	return opts
+}
+
+// newTlsConfig returns nil when neither a CA nor a client certificate is configured.
+func newTlsConfig(cfg config.ExporterConfig) (*tls.Config, error) {
+	if cfg.Certificate == "" && cfg.ClientCertificate == "" {
+		return nil, nil
+	}
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Certificate != "" {
+		pem, err := os.ReadFile(cfg.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.Certificate)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if cfg.ClientCertificate != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertificate, cfg.ClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	return tlsCfg, nil
+}
+
+// parseMillis accepts a duration ("10s") or milliseconds ("10000") as used by the OTEL environment variables.
+func parseMillis(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if millis, err := strconv.Atoi(value); err == nil {
+		return time.Duration(millis) * time.Millisecond, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,71 @@
+package otel
+
+import (
+	"context"
+	"encoding/pem"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseMillis(t *testing.T) {
+	for value, expected := range map[string]time.Duration{"": 0, "10000": 10 * time.Second, "250ms": 250 * time.Millisecond} {
+		d, err := parseMillis(value)
+		require.NoError(t, err)
+		require.Equal(t, expected, d, value)
+	}
+	_, err := parseMillis("soon")
+	require.Error(t, err)
+}
+
+func TestExporterSettings(t *testing.T) {
+	type request struct {
+		apiKey   string
+		encoding string
+	}
+	requests := make(chan request, 1)
+	collector := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- request{apiKey: r.Header.Get("Api-Key"), encoding: r.Header.Get("Content-Encoding")}
+	}))
+	defer collector.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: collector.Certificate().Raw}), 0644))
+
+	ctx := context.Background()
+	tp, err := setupTraceProvider(ctx, config.TraceConfig{
+		Enabled:         true,
+		HttpEndpointURL: collector.URL + "/v1/traces",
+		BatchSize:       10,
+		ExporterConfig: config.ExporterConfig{
+			Certificate: caFile,
+			Headers:     map[string]string{"api-key": "secret"},
+			Compression: "gzip",
+			Timeout:     "5000",
+		},
+	}, resource.Empty())
+	require.NoError(t, err)
+	_, span := tp.Tracer("test").Start(ctx, "secured")
+	span.End()
+	require.NoError(t, tp.Shutdown(ctx))
+
+	received := <-requests
+	require.Equal(t, "secret", received.apiKey)
+	require.Equal(t, "gzip", received.encoding)
+
+	_, err = newExporterSettings("", "localhost:4318", false, config.ExporterConfig{Certificate: filepath.Join(t.TempDir(), "missing.pem")})
+	require.Error(t, err)
+	_, err = newExporterSettings("", "localhost:4318", true, config.ExporterConfig{Certificate: caFile})
+	require.Error(t, err, "insecure with a certificate")
+	_, err = setupGrpcLogExporter(ctx, config.LogsConfig{GrpcEndpoint: "localhost:4317", Insecure: true,
+		ExporterConfig: config.ExporterConfig{ClientCertificate: caFile}})
+	require.Error(t, err, "insecure with a client certificate")
+	settings, err := newExporterSettings("", "", true, config.ExporterConfig{Certificate: caFile})
+	require.NoError(t, err)
+	require.Nil(t, traceHttpOptions.build(settings), "no options without an endpoint")
+}
@@ -0,0 +1,353 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/ravan/microservice-sim/internal/config"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collectorlogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// idFields are bytes in the protobuf but hex strings in OTLP-JSON.
+var idFields = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// localSink writes every export request as a line of OTLP-JSON to stdout and/or a file. The OTLP gRPC exporters
+// send to the sink over an in-process connection, so local exports carry exactly what a collector receives.
+type localSink struct {
+	outputs  []*localOutput
+	server   *grpc.Server
+	listener *pipeListener
+	conn     *grpc.ClientConn
+}
+
+// newLocalSink returns nil when neither stdout nor a file is configured.
+func newLocalSink(cfg config.LocalExporters) (*localSink, error) {
+	sink := &localSink{}
+	if cfg.Stdout {
+		out, err := openLocalOutput(localStdout)
+		if err != nil {
+			return nil, err
+		}
+		sink.outputs = append(sink.outputs, out)
+	}
+	if cfg.File != "" {
+		path, err := filepath.Abs(cfg.File)
+		if err != nil {
+			return nil, errors.Join(err, sink.Close())
+		}
+		out, err := openLocalOutput(path)
+		if err != nil {
+			return nil, errors.Join(err, sink.Close())
+		}
+		sink.outputs = append(sink.outputs, out)
+	}
+	if len(sink.outputs) == 0 {
+		return nil, nil
+	}
+
+	sink.listener = newPipeListener()
+	sink.server = grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(sink.server, traceSink{localSink: sink})
+	collectormetrics.RegisterMetricsServiceServer(sink.server, metricsSink{localSink: sink})
+	collectorlogs.RegisterLogsServiceServer(sink.server, logsSink{localSink: sink})
+	go func() { _ = sink.server.Serve(sink.listener) }()
+
+	conn, err := grpc.NewClient("passthrough:///local",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return sink.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		_ = sink.Close()
+		return nil, err
+	}
+	sink.conn = conn
+	return sink, nil
+}
+
+const localStdout = "stdout"
+
+var (
+	localOutputsMu sync.Mutex
+	localOutputs   = map[string]*localOutput{}
+)
+
+// localOutput is stdout or a file the sinks write to. The sinks of traces, metrics and logs share the output of
+// the same destination, so their lines don't interleave. It is closed when the last sink released it.
+type localOutput struct {
+	name string
+	refs int
+
+	mu   sync.Mutex
+	out  io.Writer
+	file *os.File
+}
+
+// openLocalOutput returns the output of stdout or of a file by its absolute path.
+func openLocalOutput(name string) (*localOutput, error) {
+	localOutputsMu.Lock()
+	defer localOutputsMu.Unlock()
+	if output, ok := localOutputs[name]; ok {
+		output.refs++
+		return output, nil
+	}
+	output := &localOutput{name: name, refs: 1, out: os.Stdout}
+	if name != localStdout {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		output.out, output.file = f, f
+	}
+	localOutputs[name] = output
+	return output, nil
+}
+
+func (o *localOutput) write(line []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, err := o.out.Write(line)
+	return err
+}
+
+func (o *localOutput) release() error {
+	localOutputsMu.Lock()
+	defer localOutputsMu.Unlock()
+	if o.refs--; o.refs > 0 {
+		return nil
+	}
+	delete(localOutputs, o.name)
+	if o.file != nil {
+		return o.file.Close()
+	}
+	return nil
+}
+
+type traceSink struct {
+	collectortrace.UnimplementedTraceServiceServer
+	*localSink
+}
+
+func (s traceSink) Export(_ context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	return &collectortrace.ExportTraceServiceResponse{}, s.write(req)
+}
+
+type metricsSink struct {
+	collectormetrics.UnimplementedMetricsServiceServer
+	*localSink
+}
+
+func (s metricsSink) Export(_ context.Context, req *collectormetrics.ExportMetricsServiceRequest) (*collectormetrics.ExportMetricsServiceResponse, error) {
+	return &collectormetrics.ExportMetricsServiceResponse{}, s.write(req)
+}
+
+type logsSink struct {
+	collectorlogs.UnimplementedLogsServiceServer
+	*localSink
+}
+
+func (s logsSink) Export(_ context.Context, req *collectorlogs.ExportLogsServiceRequest) (*collectorlogs.ExportLogsServiceResponse, error) {
+	return &collectorlogs.ExportLogsServiceResponse{}, s.write(req)
+}
+
+func (s *localSink) write(req proto.Message) error {
+	line, err := marshalOtlpJson(req)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	for _, output := range s.outputs {
+		err = errors.Join(err, output.write(line))
+	}
+	return err
+}
+
+// Close stops the sink after the exporters using its connection were shut down.
+func (s *localSink) Close() error {
+	var err error
+	if s.conn != nil {
+		err = s.conn.Close()
+	}
+	if s.server != nil {
+		s.server.Stop()
+	}
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	for _, output := range s.outputs {
+		err = errors.Join(err, output.release())
+	}
+	s.outputs = nil
+	return err
+}
+
+// pipeListener accepts the in-memory connections dialed by the sink's client.
+type pipeListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *pipeListener) DialContext(ctx context.Context) (net.Conn, error) {
+	server, client := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+	case <-ctx.Done():
+	}
+	_ = server.Close()
+	_ = client.Close()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, net.ErrClosed
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "local" }
+
+func setupLocalTraceExporter(ctx context.Context, cfg config.LocalExporters) (sdktrace.SpanExporter, error) {
+	sink, err := newLocalSink(cfg)
+	if err != nil || sink == nil {
+		return nil, err
+	}
+	exp, err := otlptracegrpc.New(ctx, otlptracegrpc.WithGRPCConn(sink.conn))
+	if err != nil {
+		return nil, errors.Join(err, sink.Close())
+	}
+	return localTraceExporter{SpanExporter: exp, sink: sink}, nil
+}
+
+func setupLocalMetricsExporter(ctx context.Context, cfg config.LocalExporters) (sdkmetric.Exporter, error) {
+	sink, err := newLocalSink(cfg)
+	if err != nil || sink == nil {
+		return nil, err
+	}
+	exp, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithGRPCConn(sink.conn))
+	if err != nil {
+		return nil, errors.Join(err, sink.Close())
+	}
+	return localMetricsExporter{Exporter: exp, sink: sink}, nil
+}
+
+func setupLocalLogExporter(ctx context.Context, cfg config.LocalExporters) (sdklog.Exporter, error) {
+	sink, err := newLocalSink(cfg)
+	if err != nil || sink == nil {
+		return nil, err
+	}
+	exp, err := otlploggrpc.New(ctx, otlploggrpc.WithGRPCConn(sink.conn))
+	if err != nil {
+		return nil, errors.Join(err, sink.Close())
+	}
+	return localLogExporter{Exporter: exp, sink: sink}, nil
+}
+
+// The exporters don't close connections they were given, the local exporters close their sink on shutdown.
+type localTraceExporter struct {
+	sdktrace.SpanExporter
+	sink *localSink
+}
+
+func (e localTraceExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.sink.Close())
+}
+
+type localMetricsExporter struct {
+	sdkmetric.Exporter
+	sink *localSink
+}
+
+func (e localMetricsExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.sink.Close())
+}
+
+type localLogExporter struct {
+	sdklog.Exporter
+	sink *localSink
+}
+
+func (e localLogExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.sink.Close())
+}
+
+// marshalOtlpJson encodes like the OTLP/HTTP JSON protocol: lowerCamelCase fields, enums as numbers and
+// trace and span ids as hex.
+func marshalOtlpJson(msg proto.Message) ([]byte, error) {
+	b, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	hexIds(v)
+	return json.Marshal(v)
+}
+
+func hexIds(v interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if s, ok := field.(string); ok && idFields[key] {
+				if id, err := base64.StdEncoding.DecodeString(s); err == nil {
+					value[key] = hex.EncodeToString(id)
+				}
+				continue
+			}
+			hexIds(field)
+		}
+	case []interface{}:
+		for _, item := range value {
+			hexIds(item)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package otel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalExporters(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "otel.jsonl")
+	ctx := context.Background()
+	res := resource.Empty()
+	local := config.LocalExporters{File: file}
+
+	tp, err := setupTraceProvider(ctx, config.TraceConfig{Enabled: true, LocalExporters: local}, res)
+	require.NoError(t, err)
+	_, span := tp.Tracer("test").Start(ctx, "local")
+	traceID := span.SpanContext().TraceID().String()
+	span.End()
+	require.NoError(t, tp.Shutdown(ctx))
+
+	mp, err := setupMetricProvider(ctx, config.OtelConfig{Metrics: config.MetricsConfig{Enabled: true, LocalExporters: local}}, res)
+	require.NoError(t, err)
+	counter, err := mp.Meter("test").Int64Counter("sim.test.count")
+	require.NoError(t, err)
+	counter.Add(ctx, 3)
+	require.NoError(t, mp.Shutdown(ctx))
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 2)
+
+	spans := lines[0]["resourceSpans"].([]interface{})[0].(map[string]interface{})["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	require.Equal(t, "local", spans[0].(map[string]interface{})["name"])
+	require.Equal(t, traceID, spans[0].(map[string]interface{})["traceId"], "ids are hex encoded")
+	require.Contains(t, lines[1], "resourceMetrics")
+}
+
+func TestLocalLogExporter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "logs.jsonl")
+	ctx := context.Background()
+	lp, err := setupLoggerProvider(ctx, config.LogsConfig{Enabled: true, LocalExporters: config.LocalExporters{File: file}}, resource.Empty())
+	require.NoError(t, err)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanCtx := trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  trace.SpanID{1},
+	}))
+	var record log.Record
+	record.SetBody(log.StringValue("processing order"))
+	record.SetSeverity(log.SeverityInfo)
+	lp.Logger("test").Emit(spanCtx, record)
+	require.NoError(t, lp.Shutdown(ctx))
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &line))
+	records := line["resourceLogs"].([]interface{})[0].(map[string]interface{})["scopeLogs"].([]interface{})[0].(map[string]interface{})["logRecords"].([]interface{})
+	require.Len(t, records, 1)
+	logRecord := records[0].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"stringValue": "processing order"}, logRecord["body"])
+	require.Equal(t, traceID.String(), logRecord["traceId"], "ids are hex encoded")
+}
+
+func TestValidateLocalExporters(t *testing.T) {
+	cfg := config.OtelConfig{Trace: config.TraceConfig{Enabled: true, LocalExporters: config.LocalExporters{Stdout: true}}}
+	require.NoError(t, cfg.Validate())
+	cfg.Trace.GrpcEndpoint = "localhost:4317"
+	require.NoError(t, cfg.Validate(), "local exporters combine with an endpoint")
+	cfg.Trace.HttpEndpoint = "localhost:4318"
+	require.Error(t, cfg.Validate())
+	cfg.Trace = config.TraceConfig{Enabled: true}
+	require.Error(t, cfg.Validate())
+}
+
+func TestLocalSinksShareOutputs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "otel.jsonl")
+	traces, err := newLocalSink(config.LocalExporters{Stdout: true, File: file})
+	require.NoError(t, err)
+	metrics, err := newLocalSink(config.LocalExporters{File: file})
+	require.NoError(t, err)
+	require.Same(t, traces.outputs[1], metrics.outputs[0])
+
+	require.NoError(t, traces.Close())
+	require.NoError(t, metrics.write(&collectormetrics.ExportMetricsServiceRequest{}))
+	require.NoError(t, metrics.Close())
+	require.Empty(t, localOutputs)
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "{}\n", string(b))
+}
@@ -0,0 +1,151 @@
+package otel
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/ravan/microservice-sim/internal/config"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/grpc/credentials"
+	"log/slog"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// LogHandler bridges slog records to the OTLP log exporter when logs are enabled. Records logged with a context
+// carrying a span are correlated with its trace and span id.
+var LogHandler slog.Handler
+
+func setupLoggerProvider(ctx context.Context, cfg config.LogsConfig, res *resource.Resource) (*sdklog.LoggerProvider, error) {
+	var logExporters []sdklog.Exporter
+	if exp, err := setupHttpLogExporter(ctx, cfg); err != nil {
+		return nil, err
+	} else if exp != nil {
+		logExporters = append(logExporters, exp)
+	}
+
+	if exp, err := setupGrpcLogExporter(ctx, cfg); err != nil {
+		return nil, err
+	} else if exp != nil {
+		logExporters = append(logExporters, exp)
+	}
+
+	if exp, err := setupLocalLogExporter(ctx, cfg.LocalExporters); err != nil {
+		return nil, err
+	} else if exp != nil {
+		logExporters = append(logExporters, exp)
+	}
+
+	var batchOpts []sdklog.BatchProcessorOption
+	if cfg.BatchSize > 0 {
+		batchOpts = append(batchOpts, sdklog.WithExportMaxBatchSize(cfg.BatchSize))
+	}
+	opts := []sdklog.LoggerProviderOption{sdklog.WithResource(res)}
+	for _, exp := range logExporters {
+		opts = append(opts, sdklog.WithProcessor(sdklog.NewBatchProcessor(exp, batchOpts...)))
+	}
+	lp := sdklog.NewLoggerProvider(opts...)
+	global.SetLoggerProvider(lp)
+	LogHandler = otelslog.NewHandler(meterName, otelslog.WithLoggerProvider(lp))
+	return lp, nil
+}
+
+func setupHttpLogExporter(ctx context.Context, cfg config.LogsConfig) (sdklog.Exporter, error) {
+	settings, err := newExporterSettings(cfg.HttpEndpointURL, cfg.HttpEndpoint, cfg.Insecure, cfg.ExporterConfig)
+	if err != nil {
+		return nil, err
+	}
+	opts := logHttpOptions.build(settings)
+	if opts == nil {
+		return nil, nil
+	}
+	return otlploghttp.New(ctx, opts...)
+}
+
+func setupGrpcLogExporter(ctx context.Context, cfg config.LogsConfig) (sdklog.Exporter, error) {
+	settings, err := newExporterSettings(cfg.GrpcEndpointURL, cfg.GrpcEndpoint, cfg.Insecure, cfg.ExporterConfig)
+	if err != nil {
+		return nil, err
+	}
+	opts := logGrpcOptions.build(settings)
+	if opts == nil {
+		return nil, nil
+	}
+	return otlploggrpc.New(ctx, opts...)
+}
+
+var logHttpOptions = exporterOptions[otlploghttp.Option]{
+	endpointURL:   otlploghttp.WithEndpointURL,
+	endpoint:      otlploghttp.WithEndpoint,
+	insecure:      otlploghttp.WithInsecure,
+	tls:           otlploghttp.WithTLSClientConfig,
+	headers:       otlploghttp.WithHeaders,
+	gzip:          func() otlploghttp.Option { return otlploghttp.WithCompression(otlploghttp.GzipCompression) },
+	noCompression: func() otlploghttp.Option { return otlploghttp.WithCompression(otlploghttp.NoCompression) },
+	timeout:       otlploghttp.WithTimeout,
+}
+
+var logGrpcOptions = exporterOptions[otlploggrpc.Option]{
+	endpointURL: otlploggrpc.WithEndpointURL,
+	endpoint:    otlploggrpc.WithEndpoint,
+	insecure:    otlploggrpc.WithInsecure,
+	tls: func(c *tls.Config) otlploggrpc.Option {
+		return otlploggrpc.WithTLSCredentials(credentials.NewTLS(c))
+	},
+	headers: otlploggrpc.WithHeaders,
+	gzip:    func() otlploggrpc.Option { return otlploggrpc.WithCompressor(compressionGzip) },
+	timeout: otlploggrpc.WithTimeout,
+}
+
+// fanoutHandler passes records at or above level to all handlers enabled for them.
+type fanoutHandler struct {
+	level    slog.Leveler
+	handlers []slog.Handler
+}
+
+// NewFanoutHandler writes records at or above level to every handler, e.g. to the console and to LogHandler.
+func NewFanoutHandler(level slog.Leveler, handlers ...slog.Handler) slog.Handler {
+	return &fanoutHandler{level: level, handlers: handlers}
+}
+
+func (h *fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level < h.level.Level() {
+		return false
+	}
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var err error
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, record.Level) {
+			err = errors.Join(err, handler.Handle(ctx, record.Clone()))
+		}
+	}
+	return err
+}
+
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return &fanoutHandler{level: h.level, handlers: handlers}
+}
+
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return &fanoutHandler{level: h.level, handlers: handlers}
+}
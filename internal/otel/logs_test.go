@@ -0,0 +1,60 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"sync"
+	"testing"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+type memoryExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *memoryExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, record := range records {
+		e.records = append(e.records, record.Clone())
+	}
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(context.Context) error   { return nil }
+func (e *memoryExporter) ForceFlush(context.Context) error { return nil }
+
+func TestFanoutHandler(t *testing.T) {
+	exporter := &memoryExporter{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	var console bytes.Buffer
+	logger := slog.New(NewFanoutHandler(slog.LevelInfo,
+		slog.NewTextHandler(&console, nil),
+		otelslog.NewHandler("test", otelslog.WithLoggerProvider(lp)),
+	))
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	logger.DebugContext(ctx, "filtered")
+	logger.With("endpoint", "/list").InfoContext(ctx, "processing order")
+
+	require.NotContains(t, console.String(), "filtered")
+	require.Contains(t, console.String(), `msg="processing order" endpoint=/list`)
+	require.Len(t, exporter.records, 1)
+	record := exporter.records[0]
+	require.Equal(t, "processing order", record.Body().AsString())
+	require.Equal(t, traceID, record.TraceID())
+	require.Equal(t, spanID, record.SpanID())
+	require.Equal(t, 1, record.AttributesLen())
+}
@@ -0,0 +1,14 @@
+package otel
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "microservice-sim"
+
+var Meter metric.Meter
+
+func NewMeter() {
+	Meter = otel.Meter(meterName)
+}
@@ -2,9 +2,14 @@ package otel
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/ravan/microservice-sim/internal/config"
 	"go.opentelemetry.io/otel/sdk/resource"
+	"net/http"
 	"time"
 
 	"go.opentelemetry.io/otel"
@@ -12,13 +17,62 @@ import (
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/propagation"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"google.golang.org/grpc/credentials"
 
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
 )
 
+var traceHttpOptions = exporterOptions[otlptracehttp.Option]{
+	endpointURL:   otlptracehttp.WithEndpointURL,
+	endpoint:      otlptracehttp.WithEndpoint,
+	insecure:      otlptracehttp.WithInsecure,
+	tls:           otlptracehttp.WithTLSClientConfig,
+	headers:       otlptracehttp.WithHeaders,
+	gzip:          func() otlptracehttp.Option { return otlptracehttp.WithCompression(otlptracehttp.GzipCompression) },
+	noCompression: func() otlptracehttp.Option { return otlptracehttp.WithCompression(otlptracehttp.NoCompression) },
+	timeout:       otlptracehttp.WithTimeout,
+}
+
+var traceGrpcOptions = exporterOptions[otlptracegrpc.Option]{
+	endpointURL: otlptracegrpc.WithEndpointURL,
+	endpoint:    otlptracegrpc.WithEndpoint,
+	insecure:    otlptracegrpc.WithInsecure,
+	tls: func(c *tls.Config) otlptracegrpc.Option {
+		return otlptracegrpc.WithTLSCredentials(credentials.NewTLS(c))
+	},
+	headers: otlptracegrpc.WithHeaders,
+	gzip:    func() otlptracegrpc.Option { return otlptracegrpc.WithCompressor(compressionGzip) },
+	timeout: otlptracegrpc.WithTimeout,
+}
+
+var metricHttpOptions = exporterOptions[otlpmetrichttp.Option]{
+	endpointURL:   otlpmetrichttp.WithEndpointURL,
+	endpoint:      otlpmetrichttp.WithEndpoint,
+	insecure:      otlpmetrichttp.WithInsecure,
+	tls:           otlpmetrichttp.WithTLSClientConfig,
+	headers:       otlpmetrichttp.WithHeaders,
+	gzip:          func() otlpmetrichttp.Option { return otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression) },
+	noCompression: func() otlpmetrichttp.Option { return otlpmetrichttp.WithCompression(otlpmetrichttp.NoCompression) },
+	timeout:       otlpmetrichttp.WithTimeout,
+}
+
+var metricGrpcOptions = exporterOptions[otlpmetricgrpc.Option]{
+	endpointURL: otlpmetricgrpc.WithEndpointURL,
+	endpoint:    otlpmetricgrpc.WithEndpoint,
+	insecure:    otlpmetricgrpc.WithInsecure,
+	tls: func(c *tls.Config) otlpmetricgrpc.Option {
+		return otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(c))
+	},
+	headers: otlpmetricgrpc.WithHeaders,
+	gzip:    func() otlpmetricgrpc.Option { return otlpmetricgrpc.WithCompressor(compressionGzip) },
+	timeout: otlpmetricgrpc.WithTimeout,
+}
+
+// PrometheusHandler serves the metrics in the Prometheus exposition format when prometheus is enabled.
+var PrometheusHandler http.Handler
+
 func InitializeOpenTelemetry(ctx context.Context, cfg config.OtelConfig) (shutdown func(context.Context) error, outErr error) {
 	var shutdownFuncs []func(context.Context) error
 	// Each registered cleanup will be invoked once.
@@ -31,7 +85,7 @@ func InitializeOpenTelemetry(ctx context.Context, cfg config.OtelConfig) (shutdo
 		return err
 	}
 
-	if !cfg.Trace.Enabled && !cfg.Metrics.Enabled {
+	if !cfg.Trace.Enabled && !cfg.Metrics.Enabled && !cfg.Logs.Enabled && !cfg.Prometheus.Enabled {
 		return shutdown, nil
 	} else if err := cfg.Validate(); err != nil {
 		return shutdown, err
@@ -41,10 +95,19 @@ func InitializeOpenTelemetry(ctx context.Context, cfg config.OtelConfig) (shutdo
 		outErr = errors.Join(inErr, shutdown(ctx))
 	}
 
-	otel.SetTextMapPropagator(newPropagator())
+	propagator, err := newPropagator(cfg.Propagators)
+	if err != nil {
+		return shutdown, err
+	}
+	otel.SetTextMapPropagator(propagator)
+
+	res, err := newResource(ctx, cfg.Resource)
+	if err != nil {
+		return shutdown, err
+	}
 
 	if cfg.Trace.Enabled {
-		tp, err := setupTraceProvider(ctx, cfg.Trace)
+		tp, err := setupTraceProvider(ctx, cfg.Trace, res)
 		if err != nil {
 			handleErr(err)
 			return
@@ -53,140 +116,172 @@ func InitializeOpenTelemetry(ctx context.Context, cfg config.OtelConfig) (shutdo
 		otel.SetTracerProvider(tp)
 	}
 
-	if cfg.Metrics.Enabled {
-		mp, err := setupMetricProvider(ctx, cfg.Metrics)
+	if cfg.Metrics.Enabled || cfg.Prometheus.Enabled {
+		mp, err := setupMetricProvider(ctx, cfg, res)
 		if err != nil {
 			handleErr(err)
 			return
 		}
 		shutdownFuncs = append(shutdownFuncs, mp.Shutdown)
 		otel.SetMeterProvider(mp)
+		if err := startRuntimeMetrics(cfg.Metrics, mp); err != nil {
+			handleErr(err)
+			return
+		}
+	}
+
+	if cfg.Logs.Enabled {
+		lp, err := setupLoggerProvider(ctx, cfg.Logs, res)
+		if err != nil {
+			handleErr(err)
+			return
+		}
+		shutdownFuncs = append(shutdownFuncs, lp.Shutdown)
 	}
 
 	return shutdown, outErr
 }
 
-func setupTraceProvider(ctx context.Context, cfg config.TraceConfig) (*sdktrace.TracerProvider, error) {
-	var traceExporter sdktrace.SpanExporter
+func setupTraceProvider(ctx context.Context, cfg config.TraceConfig, res *resource.Resource) (*sdktrace.TracerProvider, error) {
+	var traceExporters []sdktrace.SpanExporter
 	if exp, err := setupHttpTraceExporter(ctx, cfg); err != nil {
 		return nil, err
 	} else if exp != nil {
-		traceExporter = exp
+		traceExporters = append(traceExporters, exp)
 	}
 
 	if exp, err := setupGrpcTraceExporter(ctx, cfg); err != nil {
 		return nil, err
 	} else if exp != nil {
-		traceExporter = exp
+		traceExporters = append(traceExporters, exp)
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(traceExporter, sdktrace.WithBatchTimeout(5*time.Second)),
-		sdktrace.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(cfg.TracerName),
-		)),
-	)
-	return tp, nil
-}
-
-func setupMetricProvider(ctx context.Context, cfg config.MetricsConfig) (*sdkmetric.MeterProvider, error) {
-	var metricsExporter sdkmetric.Exporter
-	if exp, err := setupHttpMetricsExporter(ctx, cfg); err != nil {
+	if exp, err := setupLocalTraceExporter(ctx, cfg.LocalExporters); err != nil {
 		return nil, err
 	} else if exp != nil {
-		metricsExporter = exp
+		traceExporters = append(traceExporters, exp)
 	}
 
-	if exp, err := setupGrpcMetricsExporter(ctx, cfg); err != nil {
+	sampler, err := newSampler(cfg.Sampler)
+	if err != nil {
 		return nil, err
-	} else if exp != nil {
-		metricsExporter = exp
+	}
+	batchOpts := []sdktrace.BatchSpanProcessorOption{sdktrace.WithBatchTimeout(5 * time.Second)}
+	if cfg.BatchSize > 0 {
+		batchOpts = append(batchOpts, sdktrace.WithMaxExportBatchSize(cfg.BatchSize))
+	}
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithSampler(sampler), sdktrace.WithResource(res)}
+	var processors []sdktrace.SpanProcessor
+	for _, exp := range traceExporters {
+		processors = append(processors, sdktrace.NewBatchSpanProcessor(exp, batchOpts...))
+	}
+	if cfg.Sampler.Type == SamplerRules {
+		rules, err := newRuleSampler(cfg.Sampler, processors...)
+		if err != nil {
+			return nil, err
+		}
+		processors = []sdktrace.SpanProcessor{rules}
+	}
+	for _, processor := range processors {
+		opts = append(opts, sdktrace.WithSpanProcessor(processor))
 	}
 
-	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricsExporter, sdkmetric.WithInterval(1*time.Minute))))
-	return mp, nil
+	tp := sdktrace.NewTracerProvider(opts...)
+	return tp, nil
 }
 
-func setupHttpTraceExporter(ctx context.Context, cfg config.TraceConfig) (sdktrace.SpanExporter, error) {
-	var opts []otlptracehttp.Option
-	if cfg.HttpEndpointURL != "" {
-		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.HttpEndpointURL))
-	} else if cfg.HttpEndpoint != "" {
-		opts = append(opts, otlptracehttp.WithEndpoint(cfg.HttpEndpoint))
-	}
+// setupMetricProvider pushes metrics with OTLP and exposes them for Prometheus to scrape, both are optional.
+func setupMetricProvider(ctx context.Context, cfg config.OtelConfig, res *resource.Resource) (*sdkmetric.MeterProvider, error) {
+	opts := []sdkmetric.Option{sdkmetric.WithResource(res)}
+	if cfg.Metrics.Enabled {
+		var metricsExporters []sdkmetric.Exporter
+		if exp, err := setupHttpMetricsExporter(ctx, cfg.Metrics); err != nil {
+			return nil, err
+		} else if exp != nil {
+			metricsExporters = append(metricsExporters, exp)
+		}
 
-	if len(opts) == 0 {
-		return nil, nil
+		if exp, err := setupGrpcMetricsExporter(ctx, cfg.Metrics); err != nil {
+			return nil, err
+		} else if exp != nil {
+			metricsExporters = append(metricsExporters, exp)
+		}
+
+		if exp, err := setupLocalMetricsExporter(ctx, cfg.Metrics.LocalExporters); err != nil {
+			return nil, err
+		} else if exp != nil {
+			metricsExporters = append(metricsExporters, exp)
+		}
+		interval, err := parseMillis(cfg.Metrics.ExportInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metrics export interval: %w", err)
+		}
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		for _, exp := range metricsExporters {
+			opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp, sdkmetric.WithInterval(interval))))
+		}
 	}
 
-	if cfg.Insecure {
-		opts = append(opts, otlptracehttp.WithInsecure())
+	if cfg.Prometheus.Enabled {
+		registry := prometheus.NewRegistry()
+		exp, err := otelprometheus.New(otelprometheus.WithRegisterer(registry))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdkmetric.WithReader(exp))
+		PrometheusHandler = promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
 	}
 
-	return otlptracehttp.New(ctx, opts...)
+	mp := sdkmetric.NewMeterProvider(opts...)
+	return mp, nil
 }
 
-func setupGrpcTraceExporter(ctx context.Context, cfg config.TraceConfig) (sdktrace.SpanExporter, error) {
-	var opts []otlptracegrpc.Option
-	if cfg.GrpcEndpointURL != "" {
-		opts = append(opts, otlptracegrpc.WithEndpointURL(cfg.GrpcEndpointURL))
-	} else if cfg.GrpcEndpoint != "" {
-		opts = append(opts, otlptracegrpc.WithEndpoint(cfg.GrpcEndpoint))
+func setupHttpTraceExporter(ctx context.Context, cfg config.TraceConfig) (sdktrace.SpanExporter, error) {
+	settings, err := newExporterSettings(cfg.HttpEndpointURL, cfg.HttpEndpoint, cfg.Insecure, cfg.ExporterConfig)
+	if err != nil {
+		return nil, err
 	}
-
-	if len(opts) == 0 {
+	opts := traceHttpOptions.build(settings)
+	if opts == nil {
 		return nil, nil
 	}
+	return otlptracehttp.New(ctx, opts...)
+}
 
-	if cfg.Insecure {
-		opts = append(opts, otlptracegrpc.WithInsecure())
+func setupGrpcTraceExporter(ctx context.Context, cfg config.TraceConfig) (sdktrace.SpanExporter, error) {
+	settings, err := newExporterSettings(cfg.GrpcEndpointURL, cfg.GrpcEndpoint, cfg.Insecure, cfg.ExporterConfig)
+	if err != nil {
+		return nil, err
+	}
+	opts := traceGrpcOptions.build(settings)
+	if opts == nil {
+		return nil, nil
 	}
-
 	return otlptracegrpc.New(ctx, opts...)
 }
 
 func setupHttpMetricsExporter(ctx context.Context, cfg config.MetricsConfig) (sdkmetric.Exporter, error) {
-	var opts []otlpmetrichttp.Option
-	if cfg.HttpEndpointURL != "" {
-		opts = append(opts, otlpmetrichttp.WithEndpointURL(cfg.HttpEndpointURL))
-	} else if cfg.HttpEndpoint != "" {
-		opts = append(opts, otlpmetrichttp.WithEndpoint(cfg.HttpEndpoint))
+	settings, err := newExporterSettings(cfg.HttpEndpointURL, cfg.HttpEndpoint, cfg.Insecure, cfg.ExporterConfig)
+	if err != nil {
+		return nil, err
 	}
-
-	if len(opts) == 0 {
+	opts := metricHttpOptions.build(settings)
+	if opts == nil {
 		return nil, nil
 	}
-
-	if cfg.Insecure {
-		opts = append(opts, otlpmetrichttp.WithInsecure())
-	}
-
 	return otlpmetrichttp.New(ctx, opts...)
 }
 
 func setupGrpcMetricsExporter(ctx context.Context, cfg config.MetricsConfig) (sdkmetric.Exporter, error) {
-	var opts []otlpmetricgrpc.Option
-	if cfg.GrpcEndpointURL != "" {
-		opts = append(opts, otlpmetricgrpc.WithEndpointURL(cfg.GrpcEndpointURL))
-	} else if cfg.GrpcEndpoint != "" {
-		opts = append(opts, otlpmetricgrpc.WithEndpoint(cfg.GrpcEndpoint))
+	settings, err := newExporterSettings(cfg.GrpcEndpointURL, cfg.GrpcEndpoint, cfg.Insecure, cfg.ExporterConfig)
+	if err != nil {
+		return nil, err
 	}
-
-	if len(opts) == 0 {
+	opts := metricGrpcOptions.build(settings)
+	if opts == nil {
 		return nil, nil
 	}
-
-	if cfg.Insecure {
-		opts = append(opts, otlpmetricgrpc.WithInsecure())
-	}
-
 	return otlpmetricgrpc.New(ctx, opts...)
 }
-
-func newPropagator() propagation.TextMapPropagator {
-	return propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	)
-}
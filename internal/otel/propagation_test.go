@@ -0,0 +1,53 @@
+package otel
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"testing"
+)
+
+func TestNewPropagator(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+	member, err := baggage.NewMember("customer", "c-42")
+	require.NoError(t, err)
+	bag, err := baggage.New(member)
+	require.NoError(t, err)
+	ctx := baggage.ContextWithBaggage(trace.ContextWithSpanContext(context.Background(), sc), bag)
+
+	tests := []struct {
+		names   []string
+		headers []string
+	}{
+		{names: []string{"tracecontext", "baggage"}, headers: []string{"Traceparent", "Baggage"}},
+		{names: []string{"b3"}, headers: []string{"B3"}},
+		{names: []string{"b3multi"}, headers: []string{"X-B3-Traceid", "X-B3-Spanid", "X-B3-Sampled"}},
+		{names: []string{"jaeger"}, headers: []string{"Uber-Trace-Id"}},
+		{names: []string{"none"}},
+	}
+	for _, test := range tests {
+		propagator, err := newPropagator(test.names)
+		require.NoError(t, err)
+		header := http.Header{}
+		propagator.Inject(ctx, propagation.HeaderCarrier(header))
+		require.Len(t, header, len(test.headers), test.names)
+		for _, name := range test.headers {
+			require.NotEmpty(t, header.Get(name), name)
+		}
+
+		extracted := trace.SpanContextFromContext(propagator.Extract(context.Background(), propagation.HeaderCarrier(header)))
+		if len(test.headers) > 0 {
+			require.Equal(t, sc.TraceID(), extracted.TraceID(), test.names)
+		}
+	}
+
+	_, err = newPropagator([]string{"xray"})
+	require.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"github.com/ravan/microservice-sim/internal/config"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"log/slog"
+)
+
+var detectors = map[string][]resource.Option{
+	"host": {resource.WithHost()},
+	"os":   {resource.WithOS()},
+	"process": {
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+	},
+	"container": {resource.WithContainer()},
+}
+
+// newResource builds the resource shared by traces, metrics and logs. Attributes of the environment
+// (OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES) override detected and configured attributes.
+func newResource(ctx context.Context, cfg config.ResourceConfig) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	if cfg.ServiceName != "" {
+		attrs = append(attrs, semconv.ServiceName(cfg.ServiceName))
+	}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.ServiceVersion))
+	}
+	if cfg.ServiceNamespace != "" {
+		attrs = append(attrs, semconv.ServiceNamespace(cfg.ServiceNamespace))
+	}
+	if cfg.Environment != "" {
+		attrs = append(attrs, semconv.DeploymentEnvironment(cfg.Environment))
+	}
+	for key, value := range cfg.Attributes {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+
+	opts := []resource.Option{resource.WithSchemaURL(semconv.SchemaURL), resource.WithTelemetrySDK()}
+	for _, detector := range cfg.Detectors {
+		opts = append(opts, detectors[detector]...)
+	}
+	opts = append(opts, resource.WithAttributes(attrs...), resource.WithFromEnv())
+
+	res, err := resource.New(ctx, opts...)
+	if errors.Is(err, resource.ErrPartialResource) {
+		// a failing detector, e.g. container outside of a container, should not prevent telemetry
+		slog.Debug("resource partially detected", slog.Any("error", err))
+		return res, nil
+	}
+	return res, err
+}
@@ -0,0 +1,44 @@
+package otel
+
+import (
+	"context"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"testing"
+)
+
+func TestNewResource(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=staging,k8s.pod.name=orders-7d9f")
+	res, err := newResource(context.Background(), config.ResourceConfig{
+		ServiceName:      "orders",
+		ServiceVersion:   "1.2.3",
+		ServiceNamespace: "shop",
+		Environment:      "production",
+		Detectors:        []string{"host", "os", "process", "container"},
+		Attributes:       map[string]string{"team": "checkout"},
+	})
+	require.NoError(t, err)
+
+	attrs := res.Set()
+	value := func(key attribute.Key) string {
+		v, _ := attrs.Value(key)
+		return v.Emit()
+	}
+	require.Equal(t, "orders", value(semconv.ServiceNameKey))
+	require.Equal(t, "1.2.3", value(semconv.ServiceVersionKey))
+	require.Equal(t, "shop", value(semconv.ServiceNamespaceKey))
+	require.Equal(t, "staging", value(semconv.DeploymentEnvironmentKey), "the environment takes precedence")
+	require.Equal(t, "orders-7d9f", value("k8s.pod.name"))
+	require.Equal(t, "checkout", value("team"))
+	require.NotEmpty(t, value(semconv.HostNameKey))
+	require.NotEmpty(t, value(semconv.ProcessPIDKey))
+	require.Equal(t, semconv.SchemaURL, res.SchemaURL())
+
+	t.Setenv("OTEL_SERVICE_NAME", "payments")
+	res, err = newResource(context.Background(), config.ResourceConfig{ServiceName: "orders"})
+	require.NoError(t, err)
+	v, _ := res.Set().Value(semconv.ServiceNameKey)
+	require.Equal(t, "payments", v.AsString())
+}
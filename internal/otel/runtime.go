@@ -0,0 +1,71 @@
+package otel
+
+import (
+	"context"
+	"os"
+
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/shirou/gopsutil/process"
+	"go.opentelemetry.io/contrib/instrumentation/host"
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const processMeterName = "microservice-sim/process"
+
+// startRuntimeMetrics reports the Go runtime metrics (heap, garbage collection, goroutines) and the process and host
+// metrics (cpu, memory, network, resident memory and open file descriptors) through mp when enabled.
+func startRuntimeMetrics(cfg config.MetricsConfig, mp metric.MeterProvider) error {
+	if cfg.Runtime {
+		if err := runtime.Start(runtime.WithMeterProvider(mp)); err != nil {
+			return err
+		}
+	}
+	if cfg.Host {
+		if err := host.Start(host.WithMeterProvider(mp)); err != nil {
+			return err
+		}
+		return startProcessMetrics(mp)
+	}
+	return nil
+}
+
+// startProcessMetrics adds the process metrics the host instrumentation lacks.
+func startProcessMetrics(mp metric.MeterProvider) error {
+	proc, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		return err
+	}
+	meter := mp.Meter(processMeterName)
+	rss, err := meter.Int64ObservableGauge("process.memory.usage",
+		metric.WithDescription("The amount of physical memory in use."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return err
+	}
+	fds, err := meter.Int64ObservableGauge("process.open_file_descriptor.count",
+		metric.WithDescription("Number of file descriptors in use by the process."),
+		metric.WithUnit("{count}"))
+	if err != nil {
+		return err
+	}
+	threads, err := meter.Int64ObservableGauge("process.thread.count",
+		metric.WithDescription("Process threads count."),
+		metric.WithUnit("{thread}"))
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		if mem, err := proc.MemoryInfo(); err == nil {
+			o.ObserveInt64(rss, int64(mem.RSS))
+		}
+		if n, err := proc.NumFDs(); err == nil {
+			o.ObserveInt64(fds, int64(n))
+		}
+		if n, err := proc.NumThreads(); err == nil {
+			o.ObserveInt64(threads, int64(n))
+		}
+		return nil
+	}, rss, fds, threads)
+	return err
+}
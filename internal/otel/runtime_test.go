@@ -0,0 +1,42 @@
+package otel
+
+import (
+	"context"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"testing"
+)
+
+func TestStartRuntimeMetrics(t *testing.T) {
+	collect := func(cfg config.MetricsConfig) map[string]bool {
+		reader := sdkmetric.NewManualReader()
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		defer mp.Shutdown(context.Background()) //nolint:errcheck
+		require.NoError(t, startRuntimeMetrics(cfg, mp))
+
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		names := map[string]bool{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				names[m.Name] = true
+			}
+		}
+		return names
+	}
+
+	require.Empty(t, collect(config.MetricsConfig{}))
+
+	names := collect(config.MetricsConfig{Runtime: true})
+	require.True(t, names["process.runtime.go.goroutines"] || names["go.goroutine.count"], "goroutines in %v", names)
+	require.True(t, names["process.runtime.go.mem.heap_alloc"] || names["go.memory.used"])
+	require.False(t, names["process.memory.usage"])
+
+	names = collect(config.MetricsConfig{Host: true})
+	require.True(t, names["process.cpu.time"])
+	require.True(t, names["system.memory.usage"])
+	require.True(t, names["process.memory.usage"])
+	require.True(t, names["process.open_file_descriptor.count"])
+}
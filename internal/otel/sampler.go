@@ -0,0 +1,219 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/config"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+const SamplerRules = "rules"
+
+func newSampler(cfg config.SamplerConfig) (sdktrace.Sampler, error) {
+	switch cfg.Type {
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case SamplerRules:
+		// the rules sampler records every trace the parent sampled and decides when the local root span ends,
+		// outbound calls are marked sampled before that decision
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		return sdktrace.TraceIDRatioBased(cfg.Arg), nil
+	case "parentbased_always_on", "":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Arg)), nil
+	default:
+		return nil, fmt.Errorf("unknown sampler %q", cfg.Type)
+	}
+}
+
+// ruleSampler buffers the spans of a trace until its local root span ended and only passes them on when the
+// trace is kept. Traces with errors and slow traces are kept at their own ratio, other traces at the base ratio.
+// Ratios are applied to the trace id, so services with the same ratio keep the same traces. The kept spans are passed
+// on to every next processor, so a trace is buffered once however many exporters there are.
+// A trace that buffered maxSpans spans is decided early and its later spans follow that decision, a trace whose
+// local root span did not end within maxAge is decided on the spans buffered so far and evicted.
+type ruleSampler struct {
+	next      []sdktrace.SpanProcessor
+	base      sdktrace.Sampler
+	errors    sdktrace.Sampler
+	slow      sdktrace.Sampler
+	threshold time.Duration
+	maxTraces int
+	maxSpans  int
+	maxAge    time.Duration
+
+	mu     sync.Mutex
+	traces map[trace.TraceID]*bufferedTrace
+	swept  time.Time
+}
+
+// bufferedTrace holds the ended spans of a trace until it is decided.
+type bufferedTrace struct {
+	spans   []sdktrace.ReadOnlySpan
+	started time.Time
+	decided bool
+	kept    bool
+}
+
+func newRuleSampler(cfg config.SamplerConfig, next ...sdktrace.SpanProcessor) (*ruleSampler, error) {
+	var threshold, maxAge time.Duration
+	if cfg.Rules.SlowThreshold != "" {
+		var err error
+		threshold, err = time.ParseDuration(cfg.Rules.SlowThreshold)
+		if err != nil {
+			return nil, fmt.Errorf("invalid slow threshold %q: %w", cfg.Rules.SlowThreshold, err)
+		}
+	}
+	if cfg.Rules.MaxTraceAge != "" {
+		var err error
+		maxAge, err = time.ParseDuration(cfg.Rules.MaxTraceAge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max trace age %q: %w", cfg.Rules.MaxTraceAge, err)
+		}
+	}
+	return &ruleSampler{
+		next:      next,
+		base:      sdktrace.TraceIDRatioBased(cfg.Arg),
+		errors:    sdktrace.TraceIDRatioBased(cfg.Rules.ErrorRatio),
+		slow:      sdktrace.TraceIDRatioBased(cfg.Rules.SlowRatio),
+		threshold: threshold,
+		maxTraces: cfg.Rules.MaxTraces,
+		maxSpans:  cfg.Rules.MaxSpans,
+		maxAge:    maxAge,
+		traces:    map[trace.TraceID]*bufferedTrace{},
+		swept:     time.Now(),
+	}, nil
+}
+
+func (r *ruleSampler) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, next := range r.next {
+		next.OnStart(parent, s)
+	}
+}
+
+func (r *ruleSampler) OnEnd(s sdktrace.ReadOnlySpan) {
+	id := s.SpanContext().TraceID()
+	localRoot := !s.Parent().IsValid() || s.Parent().IsRemote()
+	now := time.Now()
+
+	r.mu.Lock()
+	evicted := r.evict(now)
+	buffered := r.traces[id]
+	if buffered == nil && !localRoot && len(r.traces) < r.maxTraces {
+		buffered = &bufferedTrace{started: now}
+		r.traces[id] = buffered
+	}
+	var kept []sdktrace.ReadOnlySpan
+	switch {
+	case buffered == nil:
+		// without room in the buffer a span is decided on its own
+		if r.keep(id, []sdktrace.ReadOnlySpan{s}, s) {
+			kept = []sdktrace.ReadOnlySpan{s}
+		}
+	case buffered.decided:
+		if buffered.kept {
+			kept = []sdktrace.ReadOnlySpan{s}
+		}
+	default:
+		buffered.spans = append(buffered.spans, s)
+		if localRoot || r.maxSpans > 0 && len(buffered.spans) >= r.maxSpans {
+			buffered.decided, buffered.kept = true, r.keep(id, buffered.spans, s)
+			if buffered.kept {
+				kept = buffered.spans
+			}
+			buffered.spans = nil
+		}
+	}
+	if localRoot {
+		delete(r.traces, id)
+	}
+	r.mu.Unlock()
+
+	r.pass(evicted)
+	r.pass(kept)
+}
+
+// evict decides on the traces buffered longer than maxAge and returns the spans of the kept ones. The buffer is
+// swept at most every half maxAge.
+func (r *ruleSampler) evict(now time.Time) []sdktrace.ReadOnlySpan {
+	if r.maxAge <= 0 || now.Sub(r.swept) < r.maxAge/2 {
+		return nil
+	}
+	r.swept = now
+	var kept []sdktrace.ReadOnlySpan
+	for id, buffered := range r.traces {
+		if now.Sub(buffered.started) < r.maxAge {
+			continue
+		}
+		delete(r.traces, id)
+		if len(buffered.spans) > 0 && r.keep(id, buffered.spans, buffered.spans[len(buffered.spans)-1]) {
+			kept = append(kept, buffered.spans...)
+		}
+	}
+	return kept
+}
+
+func (r *ruleSampler) pass(spans []sdktrace.ReadOnlySpan) {
+	for _, next := range r.next {
+		for _, span := range spans {
+			next.OnEnd(span)
+		}
+	}
+}
+
+func (r *ruleSampler) keep(id trace.TraceID, spans []sdktrace.ReadOnlySpan, last sdktrace.ReadOnlySpan) bool {
+	sampler := r.base
+	if r.threshold > 0 && last.EndTime().Sub(last.StartTime()) >= r.threshold {
+		sampler = r.slow
+	}
+	for _, span := range spans {
+		if span.Status().Code == codes.Error {
+			sampler = r.errors
+			break
+		}
+	}
+	result := sampler.ShouldSample(sdktrace.SamplingParameters{TraceID: id})
+	return result.Decision == sdktrace.RecordAndSample
+}
+
+func (r *ruleSampler) Shutdown(ctx context.Context) error {
+	r.flush()
+	var err error
+	for _, next := range r.next {
+		err = errors.Join(err, next.Shutdown(ctx))
+	}
+	return err
+}
+
+func (r *ruleSampler) ForceFlush(ctx context.Context) error {
+	r.flush()
+	var err error
+	for _, next := range r.next {
+		err = errors.Join(err, next.ForceFlush(ctx))
+	}
+	return err
+}
+
+// flush decides on traces whose local root span did not end.
+func (r *ruleSampler) flush() {
+	r.mu.Lock()
+	traces := r.traces
+	r.traces = map[trace.TraceID]*bufferedTrace{}
+	r.mu.Unlock()
+	for id, buffered := range traces {
+		if len(buffered.spans) > 0 && r.keep(id, buffered.spans, buffered.spans[len(buffered.spans)-1]) {
+			r.pass(buffered.spans)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package otel
+
+import (
+	"context"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+	"time"
+)
+
+func TestRuleSampler(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	cfg := config.SamplerConfig{
+		Type:  SamplerRules,
+		Arg:   0,
+		Rules: config.SamplerRules{ErrorRatio: 1, SlowThreshold: "100ms", SlowRatio: 1, MaxTraces: 10},
+	}
+	sampler, err := newSampler(cfg)
+	require.NoError(t, err)
+	processor, err := newRuleSampler(cfg, recorder)
+	require.NoError(t, err)
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler), sdktrace.WithSpanProcessor(processor)).Tracer("test")
+
+	request := func(name string, duration time.Duration, fail bool) {
+		start := time.Now()
+		ctx, root := tracer.Start(context.Background(), name, trace.WithTimestamp(start))
+		_, child := tracer.Start(ctx, name+" child")
+		if fail {
+			child.SetStatus(codes.Error, "failed")
+		}
+		child.End()
+		root.End(trace.WithTimestamp(start.Add(duration)))
+	}
+	request("fast", time.Millisecond, false)
+	request("failed", time.Millisecond, true)
+	request("slow", time.Second, false)
+
+	var names []string
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+	}
+	require.ElementsMatch(t, []string{"failed child", "failed", "slow child", "slow"}, names)
+	require.Empty(t, processor.traces)
+}
+
+func TestNewSampler(t *testing.T) {
+	for _, name := range []string{"always_on", "always_off", "traceidratio", "parentbased_always_on",
+		"parentbased_always_off", "parentbased_traceidratio", SamplerRules} {
+		_, err := newSampler(config.SamplerConfig{Type: name, Arg: 0.5})
+		require.NoError(t, err, name)
+	}
+	_, err := newSampler(config.SamplerConfig{Type: "jaeger_remote"})
+	require.Error(t, err)
+}
+
+func TestRuleSamplerParentAndFlush(t *testing.T) {
+	first, second := tracetest.NewSpanRecorder(), tracetest.NewSpanRecorder()
+	cfg := config.SamplerConfig{Type: SamplerRules, Arg: 1, Rules: config.SamplerRules{MaxTraces: 10}}
+	sampler, err := newSampler(cfg)
+	require.NoError(t, err)
+	processor, err := newRuleSampler(cfg, first, second)
+	require.NoError(t, err)
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler), sdktrace.WithSpanProcessor(processor)).Tracer("test")
+
+	remote := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+		Remote:  true,
+	})
+	_, dropped := tracer.Start(trace.ContextWithRemoteSpanContext(context.Background(), remote), "not sampled by parent")
+	require.False(t, dropped.SpanContext().IsSampled())
+	dropped.End()
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+	require.Empty(t, first.Ended())
+	require.NoError(t, processor.ForceFlush(context.Background()))
+	root.End()
+
+	for _, recorder := range []*tracetest.SpanRecorder{first, second} {
+		require.Len(t, recorder.Ended(), 2)
+		require.Equal(t, "child", recorder.Ended()[0].Name())
+	}
+}
+
+func TestRuleSamplerBufferLimits(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	cfg := config.SamplerConfig{Type: SamplerRules, Arg: 1, Rules: config.SamplerRules{MaxTraces: 10, MaxSpans: 2, MaxTraceAge: "20ms"}}
+	sampler, err := newSampler(cfg)
+	require.NoError(t, err)
+	processor, err := newRuleSampler(cfg, recorder)
+	require.NoError(t, err)
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler), sdktrace.WithSpanProcessor(processor)).Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	for _, name := range []string{"first", "second", "third"} {
+		_, child := tracer.Start(ctx, name)
+		child.End()
+	}
+	require.Len(t, recorder.Ended(), 3)
+	root.End()
+	require.Len(t, recorder.Ended(), 4)
+
+	ctx, abandoned := tracer.Start(context.Background(), "abandoned")
+	_, child := tracer.Start(ctx, "abandoned child")
+	child.End()
+	require.Len(t, recorder.Ended(), 4)
+	time.Sleep(30 * time.Millisecond)
+	_, other := tracer.Start(context.Background(), "other")
+	other.End()
+	require.Len(t, recorder.Ended(), 6)
+	require.Empty(t, processor.traces)
+	abandoned.End()
+}
@@ -0,0 +1,78 @@
+package otel
+
+import (
+	"context"
+	"encoding/binary"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"strconv"
+)
+
+// Conventions for the names and format of the trace context fields in log output.
+const (
+	ConventionW3C     = "w3c"
+	ConventionDatadog = "datadog"
+	ConventionElastic = "elastic"
+)
+
+// traceContextHandler adds the trace and span id of the span in the record context to the record.
+type traceContextHandler struct {
+	slog.Handler
+	traceKey string
+	spanKey  string
+	datadog  bool
+}
+
+// NewTraceContextHandler wraps handler adding trace and span id fields named by convention:
+//
+//	w3c     - trace_id and span_id as hex
+//	datadog - dd.trace_id and dd.span_id as decimal, using the lower 64 bits of the trace id
+//	elastic - trace.id and span.id as hex
+//
+// traceKey and spanKey override the field names of the convention when set.
+func NewTraceContextHandler(handler slog.Handler, convention, traceKey, spanKey string) slog.Handler {
+	h := &traceContextHandler{Handler: handler, traceKey: "trace_id", spanKey: "span_id"}
+	switch convention {
+	case ConventionDatadog:
+		h.traceKey, h.spanKey, h.datadog = "dd.trace_id", "dd.span_id", true
+	case ConventionElastic:
+		h.traceKey, h.spanKey = "trace.id", "span.id"
+	}
+	if traceKey != "" {
+		h.traceKey = traceKey
+	}
+	if spanKey != "" {
+		h.spanKey = spanKey
+	}
+	return h
+}
+
+func (h *traceContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		if h.datadog {
+			traceID, spanID := sc.TraceID(), sc.SpanID()
+			record.AddAttrs(
+				slog.String(h.traceKey, strconv.FormatUint(binary.BigEndian.Uint64(traceID[8:]), 10)),
+				slog.String(h.spanKey, strconv.FormatUint(binary.BigEndian.Uint64(spanID[:]), 10)),
+			)
+		} else {
+			record.AddAttrs(
+				slog.String(h.traceKey, sc.TraceID().String()),
+				slog.String(h.spanKey, sc.SpanID().String()),
+			)
+		}
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *traceContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.Handler = h.Handler.WithAttrs(attrs)
+	return &clone
+}
+
+func (h *traceContextHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.Handler = h.Handler.WithGroup(name)
+	return &clone
+}
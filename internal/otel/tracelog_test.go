@@ -0,0 +1,39 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"testing"
+)
+
+func TestTraceContextHandler(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	tests := []struct {
+		convention, traceKey, spanKey string
+		expected                      string
+	}{
+		{ConventionW3C, "", "", "trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7"},
+		{ConventionElastic, "", "", "trace.id=4bf92f3577b34da6a3ce929d0e0e4736 span.id=00f067aa0ba902b7"},
+		{ConventionDatadog, "", "", "dd.trace_id=11803532876627986230 dd.span_id=67667974448284343"},
+		{ConventionW3C, "traceId", "spanId", "traceId=4bf92f3577b34da6a3ce929d0e0e4736 spanId=00f067aa0ba902b7"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		logger := slog.New(NewTraceContextHandler(slog.NewTextHandler(&out, nil), tt.convention, tt.traceKey, tt.spanKey))
+		logger.With("endpoint", "/list").InfoContext(ctx, "processing")
+		require.Contains(t, out.String(), "endpoint=/list "+tt.expected, tt.convention)
+
+		out.Reset()
+		logger.Info("startup")
+		require.NotContains(t, out.String(), tt.expected, tt.convention)
+	}
+}
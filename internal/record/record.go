@@ -0,0 +1,162 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/util"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	traceParent    = "traceparent"
+	encodingBase64 = "base64"
+	maxLineSize    = 16 * 1024 * 1024
+)
+
+// Entry is a recorded request, written as a single JSON line.
+type Entry struct {
+	Timestamp    time.Time   `json:"timestamp"`
+	Method       string      `json:"method"`
+	Path         string      `json:"path"`
+	Headers      http.Header `json:"headers,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+	Truncated    bool        `json:"truncated,omitempty"`
+	TraceID      string      `json:"traceId,omitempty"`
+	Status       int         `json:"status"`
+	LatencyMs    float64     `json:"latencyMs"`
+}
+
+// Recorder appends every request passing its middleware to a JSONL file.
+type Recorder struct {
+	maxBodySize int64
+	skip        func(r *http.Request) bool
+	mu          sync.Mutex
+	file        *os.File
+	encoder     *json.Encoder
+}
+
+// NewRecorder opens file for appending. Bodies are recorded up to maxBodySize bytes,
+// requests for which skip returns true are not recorded.
+func NewRecorder(file string, maxBodySize int64, skip func(r *http.Request) bool) (*Recorder, error) {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		maxBodySize: maxBodySize,
+		skip:        skip,
+		file:        f,
+		encoder:     json.NewEncoder(f),
+	}, nil
+}
+
+func (rec *Recorder) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.file.Close()
+}
+
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rec.skip != nil && rec.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		entry := Entry{
+			Timestamp: time.Now(),
+			Method:    r.Method,
+			Path:      r.URL.RequestURI(),
+			Headers:   r.Header.Clone(),
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			body, err := io.ReadAll(io.LimitReader(r.Body, rec.maxBodySize+1))
+			if err == nil {
+				entry.Truncated = int64(len(body)) > rec.maxBodySize
+				entry.setBody(body[:min(int64(len(body)), rec.maxBodySize)])
+			}
+			// hand the complete body to the handler
+			r.Body = readCloser{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		}
+
+		sw := util.NewStatusWriter(w)
+		next.ServeHTTP(sw, r)
+
+		entry.Status = sw.Status()
+		entry.LatencyMs = float64(time.Since(entry.Timestamp).Microseconds()) / 1000
+		entry.TraceID = traceID(r)
+		rec.write(&entry)
+	})
+}
+
+func (rec *Recorder) write(entry *Entry) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	// errors are ignored, recording must never fail the request
+	_ = rec.encoder.Encode(entry)
+}
+
+func (e *Entry) setBody(body []byte) {
+	if utf8.Valid(body) {
+		e.Body = string(body)
+		return
+	}
+	e.Body = base64.StdEncoding.EncodeToString(body)
+	e.BodyEncoding = encodingBase64
+}
+
+// BodyBytes returns the decoded body.
+func (e *Entry) BodyBytes() ([]byte, error) {
+	if e.BodyEncoding == encodingBase64 {
+		return base64.StdEncoding.DecodeString(e.Body)
+	}
+	return []byte(e.Body), nil
+}
+
+// traceID returns the trace of the server span, or the incoming trace when tracing is disabled.
+func traceID(r *http.Request) string {
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	// traceparent is "version-traceid-parentid-flags"
+	if parts := strings.Split(r.Header.Get(traceParent), "-"); len(parts) == 4 {
+		return parts[1]
+	}
+	return ""
+}
+
+// Read calls fn for every entry in a JSONL stream.
+func Read(r io.Reader, fn func(Entry) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("invalid entry on line %d: %w", line, err)
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
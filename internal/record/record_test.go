@@ -0,0 +1,105 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/ravan/microservice-sim/internal/otel"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "requests.jsonl")
+	recorder, err := NewRecorder(file, 4, func(r *http.Request) bool {
+		return r.URL.Path == "/health"
+	})
+	require.NoError(t, err)
+
+	var received string
+	handler := recorder.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/orders?id=1", strings.NewReader("order-1"))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.Equal(t, "order-1", received)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.NoError(t, recorder.Close())
+
+	f, err := os.Open(file)
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []Entry
+	require.NoError(t, Read(f, func(entry Entry) error {
+		entries = append(entries, entry)
+		return nil
+	}))
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	require.Equal(t, "POST", entry.Method)
+	require.Equal(t, "/orders?id=1", entry.Path)
+	require.Equal(t, "orde", entry.Body)
+	require.True(t, entry.Truncated)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entry.TraceID)
+	require.Equal(t, http.StatusCreated, entry.Status)
+}
+
+func TestEntryBinaryBody(t *testing.T) {
+	var entry Entry
+	entry.setBody([]byte{0xff, 0x00, 0xfe})
+	require.Equal(t, encodingBase64, entry.BodyEncoding)
+	body, err := entry.BodyBytes()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xff, 0x00, 0xfe}, body)
+}
+
+func TestReplay(t *testing.T) {
+	otel.NewTracer(config.OtelConfig{})
+	var mu sync.Mutex
+	var paths, bodies []string
+	var arrivals []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.RequestURI())
+		bodies = append(bodies, string(body))
+		arrivals = append(arrivals, time.Now())
+		require.Empty(t, r.Header.Get("traceparent"))
+		require.Equal(t, "demo", r.Header.Get("X-Scenario"))
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	entries := []Entry{
+		{Timestamp: start, Method: "GET", Path: "/list?page=1", Status: 200},
+		{Timestamp: start.Add(400 * time.Millisecond), Method: "POST", Path: "/save", Body: "item", Status: 201},
+	}
+	var jsonl bytes.Buffer
+	for _, entry := range entries {
+		entry.Headers = http.Header{"X-Scenario": {"demo"}, "Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+		require.NoError(t, json.NewEncoder(&jsonl).Encode(entry))
+	}
+
+	result, err := Replay(context.Background(), &jsonl, ReplayOptions{Target: srv.URL, Speed: 2, Timeout: time.Second})
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Requests)
+	require.Equal(t, 0, result.Errors)
+	// the second entry was recorded with status 201 but the target responds with 200
+	require.Equal(t, 1, result.Mismatches)
+	require.Equal(t, []string{"/list?page=1", "/save"}, paths)
+	require.Equal(t, "item", bodies[1])
+	require.InDelta(t, 200*time.Millisecond, arrivals[1].Sub(arrivals[0]), float64(100*time.Millisecond))
+}
@@ -0,0 +1,144 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/load"
+	"github.com/ravan/microservice-sim/internal/otel"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// skipHeaders are not replayed, replayed requests start their own trace.
+var skipHeaders = []string{"Host", "Content-Length", "Connection", "Traceparent", "Tracestate", "Baggage"}
+
+type ReplayOptions struct {
+	// Target is the base url requests are sent to, e.g. "http://localhost:8080".
+	Target string
+	// Speed multiplies the original pace, 2 replays twice as fast. 0 replays without waiting.
+	Speed   float64
+	Timeout time.Duration
+}
+
+type ReplayResult struct {
+	Requests   int
+	Errors     int
+	Mismatches int
+	Elapsed    time.Duration
+	Latency    *load.Histogram
+}
+
+// Replay sends the recorded requests to the target, preserving their inter-arrival timing scaled by the speed.
+func Replay(ctx context.Context, r io.Reader, opts ReplayOptions) (*ReplayResult, error) {
+	target := strings.TrimSuffix(opts.Target, "/")
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	client := &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+	result := &ReplayResult{Latency: load.NewHistogram()}
+	var mu sync.Mutex
+	var inflight sync.WaitGroup
+	var first time.Time
+	start := time.Now()
+
+	err := Read(r, func(entry Entry) error {
+		if first.IsZero() {
+			first = entry.Timestamp
+		}
+		if opts.Speed > 0 {
+			due := start.Add(time.Duration(float64(entry.Timestamp.Sub(first)) / opts.Speed))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Until(due)):
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		inflight.Add(1)
+		go func() {
+			defer inflight.Done()
+			sendStart := time.Now()
+			status, err := replayEntry(ctx, client, target, &entry)
+			latency := time.Since(sendStart)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Requests++
+			result.Latency.Record(latency)
+			if err != nil {
+				result.Errors++
+				slog.Debug("replay failed", "method", entry.Method, "path", entry.Path, slog.Any("error", err))
+			} else if status != entry.Status {
+				result.Mismatches++
+				slog.Debug("replay status differs", "method", entry.Method, "path", entry.Path,
+					"recorded", entry.Status, "status", status)
+			}
+		}()
+		return nil
+	})
+	inflight.Wait()
+	result.Elapsed = time.Since(start)
+	if ctx.Err() != nil {
+		// interrupted, report what was replayed so far
+		err = nil
+	}
+	return result, err
+}
+
+func replayEntry(ctx context.Context, client *http.Client, target string, entry *Entry) (int, error) {
+	ctx, span := otel.Tracer.Start(ctx, fmt.Sprintf("replay %s %s", entry.Method, entry.Path), trace.WithNewRoot())
+	defer span.End()
+
+	body, err := entry.BodyBytes()
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, entry.Method, target+entry.Path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	for key, values := range entry.Headers {
+		if !isSkipped(key) {
+			req.Header[key] = values
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, nil
+}
+
+func isSkipped(header string) bool {
+	for _, skip := range skipHeaders {
+		if strings.EqualFold(header, skip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ReplayResult) Write(out io.Writer) {
+	fmt.Fprintf(out, "\n%d requests replayed in %s, %d errors, %d status mismatches\n",
+		r.Requests, r.Elapsed.Round(time.Millisecond), r.Errors, r.Mismatches)
+	fmt.Fprintf(out, "latency p50=%s p90=%s p99=%s max=%s\n",
+		load.FormatLatency(r.Latency.Percentile(50)), load.FormatLatency(r.Latency.Percentile(90)),
+		load.FormatLatency(r.Latency.Percentile(99)), load.FormatLatency(r.Latency.Max()))
+}
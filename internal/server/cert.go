@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/config"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	FailOnExpiryRequests = "requests"
+	FailOnExpiryHealth   = "health"
+	FailOnExpiryAll      = "all"
+)
+
+var certMonitor *certificateMonitor
+
+// certificateMonitor periodically re-reads the certificate, logging escalating warnings as expiry approaches.
+type certificateMonitor struct {
+	conf       *config.Certificate
+	thresholds []time.Duration
+	mu         sync.Mutex
+	notAfter   time.Time
+	warned     int
+	expired    bool
+}
+
+func initCertificateMonitor(ctx context.Context, conf *config.Certificate) {
+	if !conf.Enabled {
+		return
+	}
+	interval, err := time.ParseDuration(conf.CheckInterval)
+	if err != nil {
+		slog.Error("Error parsing certificate check interval", "interval", conf.CheckInterval, "error", err)
+		return
+	}
+	var thresholds []time.Duration
+	for _, warnBefore := range conf.WarnBefore {
+		threshold, err := time.ParseDuration(warnBefore)
+		if err != nil {
+			slog.Error("Error parsing certificate warning threshold", "warnBefore", warnBefore, "error", err)
+			continue
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	// longest threshold first so warnings escalate as expiry approaches
+	slices.Sort(thresholds)
+	slices.Reverse(thresholds)
+
+	certMonitor = &certificateMonitor{
+		conf:       conf,
+		thresholds: thresholds,
+	}
+	certMonitor.check()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				certMonitor.check()
+			}
+		}
+	}()
+}
+
+func (m *certificateMonitor) check() {
+	cert, err := loadCertificate(m.conf.CertFile, m.conf.KeyFile)
+	if err != nil {
+		slog.Error("certificate check failed", "certificate", m.conf.CertFile, slog.Any("error", err))
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !cert.NotAfter.Equal(m.notAfter) {
+		// certificate was replaced, start warning from scratch
+		m.notAfter = cert.NotAfter
+		m.warned = 0
+		m.expired = false
+	}
+	remaining := time.Until(m.notAfter)
+	if remaining <= 0 {
+		if !m.expired {
+			slog.Error("certificate expired", "certificate", m.conf.CertFile, "notAfter", m.notAfter)
+		}
+		m.expired = true
+		return
+	}
+	crossed := 0
+	for _, threshold := range m.thresholds {
+		if remaining <= threshold {
+			crossed++
+		}
+	}
+	if crossed > m.warned {
+		slog.Warn("certificate expires soon", "certificate", m.conf.CertFile, "notAfter", m.notAfter,
+			"remaining", remaining.Round(time.Minute), "threshold", m.thresholds[crossed-1])
+		m.warned = crossed
+	}
+}
+
+// daysUntilExpiry returns the fractional days until the certificate expires, negative once expired.
+func (m *certificateMonitor) daysUntilExpiry() (float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.notAfter.IsZero() {
+		return 0, false
+	}
+	return time.Until(m.notAfter).Hours() / 24, true
+}
+
+// failing reports whether the certificate expired and the given kind of call should fail.
+func (m *certificateMonitor) failing(kind string) bool {
+	if m == nil {
+		return false
+	}
+	failOn := m.conf.FailOnExpiry
+	if failOn != kind && failOn != FailOnExpiryAll {
+		return false
+	}
+	days, ok := m.daysUntilExpiry()
+	return ok && days <= 0
+}
+
+func validateCertAndKey(certPath, keyPath string) error {
+	certParsed, err := loadCertificate(certPath, keyPath)
+	if err != nil {
+		return err
+	}
+
+	if certParsed.NotAfter.Before(time.Now()) {
+		return fmt.Errorf("certificate expired on %s", certParsed.NotAfter.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func loadCertificate(certPath, keyPath string) (*x509.Certificate, error) {
+	// Read the certificate file
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file: %v", err)
+	}
+
+	// Read the key file
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+
+	// Load the certificate
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate and key pair: %v", err)
+	}
+
+	// Parse the certificate to ensure it is valid
+	certParsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate: %v", err)
+	}
+	return certParsed, nil
+}
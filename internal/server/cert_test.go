@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestCertificateExpired(t *testing.T) {
@@ -44,6 +45,36 @@ func TestCertificateValid(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestCertificateMonitor(t *testing.T) {
+	tempDir := t.TempDir()
+	certFile := filepath.Join(tempDir, "cert.pem")
+	keyFile := filepath.Join(tempDir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, []byte(ValidCert), 0644))
+	require.NoError(t, os.WriteFile(keyFile, []byte(ValidKey), 0644))
+
+	monitor := &certificateMonitor{
+		conf: &config.Certificate{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			FailOnExpiry: FailOnExpiryRequests,
+		},
+		thresholds: []time.Duration{100 * 365 * 24 * time.Hour, time.Hour},
+	}
+	monitor.check()
+	days, ok := monitor.daysUntilExpiry()
+	require.True(t, ok)
+	require.Greater(t, days, 0.0)
+	require.Equal(t, 1, monitor.warned)
+	require.False(t, monitor.failing(FailOnExpiryRequests))
+
+	require.NoError(t, os.WriteFile(certFile, []byte(ExpiredCert), 0644))
+	require.NoError(t, os.WriteFile(keyFile, []byte(ExpiredKey), 0644))
+	monitor.check()
+	require.True(t, monitor.expired)
+	require.True(t, monitor.failing(FailOnExpiryRequests))
+	require.False(t, monitor.failing(FailOnExpiryHealth))
+}
+
 const ExpiredCert = `-----BEGIN CERTIFICATE-----
 MIIFazCCA1OgAwIBAgIUAxlaoUqIXtwMdnsh4S4wj0ME5WIwDQYJKoZIhvcNAQEL
 BQAwRTELMAkGA1UEBhMCQVUxEzARBgNVBAgMClNvbWUtU3RhdGUxITAfBgNVBAoM
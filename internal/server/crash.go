@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/ravan/microservice-sim/internal/crash"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+var crashConf *config.Crash
+var crashRequests atomic.Int64
+
+// initCrash arms the crash simulation. With crashingStarts set only the first starts crash, so a restarting
+// container ends up in a crash loop that eventually heals. Without a trigger the service crashes right away.
+func initCrash(conf *config.Crash) {
+	if !conf.Enabled {
+		return
+	}
+	if conf.CrashingStarts > 0 {
+		starts, err := crash.RecordStart(conf.StateFile)
+		if err != nil {
+			slog.Error("failed to record start for crash simulation", "stateFile", conf.StateFile, slog.Any("error", err))
+			return
+		}
+		if starts > conf.CrashingStarts {
+			slog.Info("crash simulation healed", "starts", starts, "crashingStarts", conf.CrashingStarts)
+			return
+		}
+		slog.Debug("crash simulation armed", "starts", starts, "crashingStarts", conf.CrashingStarts)
+	}
+	crashConf = conf
+
+	if conf.Delay == "" && conf.AfterRequests <= 0 && conf.OnEndpoint == "" {
+		crashNow("crashed on startup")
+	}
+	if conf.Delay != "" {
+		delay, err := time.ParseDuration(conf.Delay)
+		if err != nil {
+			slog.Error("Error parsing crash delay", "delay", conf.Delay, "error", err)
+			return
+		}
+		time.AfterFunc(delay, func() {
+			crashNow(fmt.Sprintf("crashed after %s", delay))
+		})
+	}
+}
+
+func checkCrash(endpoint *config.Endpoint) {
+	if crashConf == nil {
+		return
+	}
+	if crashConf.OnEndpoint == endpoint.Uri {
+		crashNow(fmt.Sprintf("crashed when calling %s", endpoint.Uri))
+	}
+	if crashConf.AfterRequests > 0 && crashRequests.Add(1) >= int64(crashConf.AfterRequests) {
+		crashNow(fmt.Sprintf("crashed after %d requests", crashConf.AfterRequests))
+	}
+}
+
+func crashNow(reason string) {
+	crash.Now(crashConf.Mode, crashConf.ExitCode, fmt.Sprintf("%s: %s", crashConf.Message, reason))
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/config"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+const grpcMessageType = ".google.protobuf.Struct"
+
+var grpcMethods = make(map[string]grpcMethod)
+
+// grpcMethod is the uri of a gRPC endpoint split in its service and method name.
+type grpcMethod struct {
+	service string
+	method  string
+}
+
+func parseGrpcMethod(uri string) (grpcMethod, error) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(uri, "/"), "/")
+	if !protoreflect.FullName(service).IsValid() || !protoreflect.Name(method).IsValid() {
+		return grpcMethod{}, fmt.Errorf("invalid grpc endpoint %q, expected package.Service/Method", uri)
+	}
+	return grpcMethod{service: service, method: method}, nil
+}
+
+// initGrpc serves the gRPC endpoints on their own listener that is stopped gracefully when ctx is done.
+func initGrpc(ctx context.Context, address string, conf *config.Grpc) error {
+	if !conf.Enabled {
+		return nil
+	}
+	srv, err := newGrpcServer(conf)
+	if err != nil {
+		return err
+	}
+	addr := fmt.Sprintf("%s:%d", address, conf.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+	go func() {
+		slog.Info("Listening for gRPC on", slog.String("address", addr))
+		if err := srv.Serve(listener); err != nil {
+			slog.Error("gRPC server failed", slog.Any("error", err))
+		}
+	}()
+	return nil
+}
+
+// newGrpcServer registers a service per service name of the endpoints, the methods take and return a
+// google.protobuf.Struct. Reflection describes the services with descriptors generated for them.
+func newGrpcServer(conf *config.Grpc) (*grpc.Server, error) {
+	services := make(map[string]*grpc.ServiceDesc)
+	var serviceNames []string
+	for i := range conf.Endpoints {
+		endpoint := &conf.Endpoints[i]
+		method, err := parseGrpcMethod(endpoint.Uri)
+		if err != nil {
+			return nil, err
+		}
+		grpcMethods[endpoint.Uri] = method
+		registerEndpoint(endpoint)
+
+		desc, ok := services[method.service]
+		if !ok {
+			desc = &grpc.ServiceDesc{
+				ServiceName: method.service,
+				HandlerType: (*interface{})(nil),
+				Metadata:    grpcDescriptorPath(method.service),
+			}
+			services[method.service] = desc
+			serviceNames = append(serviceNames, method.service)
+		}
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: method.method,
+			Handler:    grpcMethodHandler(endpoint),
+		})
+	}
+
+	var opts []grpc.ServerOption
+	if otelActive {
+		opts = append(opts, grpc.StatsHandler(otelgrpc.NewServerHandler()))
+	}
+	srv := grpc.NewServer(opts...)
+	descriptors := grpcDescriptors{Files: &protoregistry.Files{}}
+	for _, name := range serviceNames {
+		if err := descriptors.register(services[name]); err != nil {
+			return nil, err
+		}
+		srv.RegisterService(services[name], nil)
+	}
+	if conf.Reflection {
+		opts := reflection.ServerOptions{Services: srv, DescriptorResolver: descriptors}
+		reflectionv1.RegisterServerReflectionServer(srv, reflection.NewServerV1(opts))
+		reflectionv1alpha.RegisterServerReflectionServer(srv, reflection.NewServer(opts))
+	}
+	return srv, nil
+}
+
+func grpcMethodHandler(endpoint *config.Endpoint) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(_ interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := new(structpb.Struct)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return grpcEndpointHandler(ctx, endpoint, req.(*structpb.Struct))
+		}
+		if interceptor == nil {
+			return handler(ctx, in)
+		}
+		return interceptor(ctx, in, &grpc.UnaryServerInfo{FullMethod: "/" + strings.TrimPrefix(endpoint.Uri, "/")}, handler)
+	}
+}
+
+// grpcEndpointHandler handles a gRPC call like an HTTP request. Expired certificates fail with Unavailable,
+// simulated errors and failed spans or routes with Internal.
+func grpcEndpointHandler(ctx context.Context, endpoint *config.Endpoint, in *structpb.Struct) (out *structpb.Struct, err error) {
+	data := getDataMap()
+	data["Endpoint"] = endpoint
+	data["Request"] = in.AsMap()
+	data["Metadata"], _ = metadata.FromIncomingContext(ctx)
+	start := time.Now()
+	defer func() {
+		recordGrpcEndpoint(ctx, endpoint, status.Code(err), time.Since(start))
+	}()
+
+	checkCrash(endpoint)
+	annotateSpan(ctx, &endpoint.SpanAnnotations, data)
+	if err := checkCertificate(ctx, endpoint); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if err := simulateError(ctx, endpoint, data); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	endpoint.Logging.LogBefore(ctx, data)
+	slog.DebugContext(ctx, "grpc call", "method", endpoint.Uri)
+	if err := processEndpoint(&ctx, endpoint, data); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	endpoint.Logging.LogAfter(ctx, data)
+
+	out, err = structpb.NewStruct(successResponseBody(endpoint))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return out, nil
+}
+
+func grpcDescriptorPath(service string) string {
+	return strings.ReplaceAll(service, ".", "/") + ".proto"
+}
+
+// grpcDescriptors resolves the descriptors generated for the gRPC services and everything else, like
+// google.protobuf.Struct and the reflection service, from the global registry.
+type grpcDescriptors struct {
+	*protoregistry.Files
+}
+
+// register generates the file descriptor of a service.
+func (d grpcDescriptors) register(desc *grpc.ServiceDesc) error {
+	name := protoreflect.FullName(desc.ServiceName)
+	service := &descriptorpb.ServiceDescriptorProto{Name: proto.String(string(name.Name()))}
+	for _, method := range desc.Methods {
+		service.Method = append(service.Method, &descriptorpb.MethodDescriptorProto{
+			Name:       proto.String(method.MethodName),
+			InputType:  proto.String(grpcMessageType),
+			OutputType: proto.String(grpcMessageType),
+		})
+	}
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String(desc.Metadata.(string)),
+		Package:    proto.String(string(name.Parent())),
+		Dependency: []string{structpb.File_google_protobuf_struct_proto.Path()},
+		Service:    []*descriptorpb.ServiceDescriptorProto{service},
+		Syntax:     proto.String("proto3"),
+	}, d)
+	if err != nil {
+		return fmt.Errorf("failed to describe grpc service %s: %w", desc.ServiceName, err)
+	}
+	return d.RegisterFile(file)
+}
+
+func (d grpcDescriptors) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if file, err := d.Files.FindFileByPath(path); err == nil {
+		return file, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (d grpcDescriptors) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if desc, err := d.Files.FindDescriptorByName(name); err == nil {
+		return desc, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"net"
+	"testing"
+)
+
+func TestParseGrpcMethod(t *testing.T) {
+	method, err := parseGrpcMethod("shop.v1.Orders/Get")
+	require.NoError(t, err)
+	require.Equal(t, grpcMethod{service: "shop.v1.Orders", method: "Get"}, method)
+
+	for _, uri := range []string{"/orders", "shop.Orders", "shop.Orders/Get/Item", "shop..Orders/Get"} {
+		_, err := parseGrpcMethod(uri)
+		require.Error(t, err, uri)
+	}
+}
+
+func TestGrpcServer(t *testing.T) {
+	srv, err := newGrpcServer(&config.Grpc{
+		Reflection: true,
+		Endpoints: []config.Endpoint{
+			{Uri: "shop.Orders/Get", Body: map[string]interface{}{"id": "42"}},
+			{Uri: "shop.Orders/Fail", ErrorOnCall: 1},
+			{Uri: "shop.Payments/Pay", Spans: []config.Span{{Name: "charge", ErrorRate: 1, StopOnFail: true}}},
+		},
+	})
+	require.NoError(t, err)
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(listener) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx := context.Background()
+
+	in, err := structpb.NewStruct(map[string]interface{}{"order": "42"})
+	require.NoError(t, err)
+	out := new(structpb.Struct)
+	require.NoError(t, conn.Invoke(ctx, "/shop.Orders/Get", in, out))
+	require.Equal(t, map[string]interface{}{"success": true, "id": "42"}, out.AsMap())
+
+	err = conn.Invoke(ctx, "/shop.Orders/Fail", in, out)
+	require.Equal(t, codes.Internal, status.Code(err))
+	require.Contains(t, status.Convert(err).Message(), "shop.Orders/Fail")
+
+	err = conn.Invoke(ctx, "/shop.Payments/Pay", in, out)
+	require.Equal(t, codes.Internal, status.Code(err))
+
+	stream, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&reflectionv1.ServerReflectionRequest{
+		MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	var services []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	require.Subset(t, services, []string{"shop.Orders", "shop.Payments"})
+
+	require.NoError(t, stream.Send(&reflectionv1.ServerReflectionRequest{
+		MessageRequest: &reflectionv1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "shop.Orders"},
+	}))
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	files := resp.GetFileDescriptorResponse().GetFileDescriptorProto()
+	require.NotEmpty(t, files)
+	file := &descriptorpb.FileDescriptorProto{}
+	require.NoError(t, proto.Unmarshal(files[0], file))
+	require.Equal(t, "shop", file.GetPackage())
+	require.Equal(t, "Orders", file.GetService()[0].GetName())
+	require.Len(t, file.GetService()[0].GetMethod(), 2)
+	require.Equal(t, ".google.protobuf.Struct", file.GetService()[0].GetMethod()[0].GetInputType())
+}
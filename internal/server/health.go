@@ -0,0 +1,43 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/ravan/microservice-sim/internal/config"
+	"log/slog"
+	"net/http"
+)
+
+func initHealth(mux *http.ServeMux, uri string, endpoints []config.Endpoint) {
+	if uri == "" {
+		return
+	}
+	for i := range endpoints {
+		if endpoints[i].Uri == uri {
+			slog.Debug("health endpoint overridden by endpoint definition", "uri", uri)
+			return
+		}
+	}
+	mux.HandleFunc(uri, healthHandler)
+}
+
+func healthHandler(w http.ResponseWriter, _ *http.Request) {
+	status := map[string]interface{}{
+		"status": "ok",
+	}
+	for kind, supervisor := range stressNgSupervisors() {
+		status[kind] = supervisor.Status()
+	}
+	if certMonitor != nil {
+		if days, ok := certMonitor.daysUntilExpiry(); ok {
+			status["certificateDaysUntilExpiry"] = days
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if certMonitor.failing(FailOnExpiryHealth) {
+		status["status"] = "certificate expired"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		setupInternalServerError(w, err)
+	}
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/ravan/microservice-sim/internal/otel"
+	"github.com/ravan/microservice-sim/internal/stress"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc/codes"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	stressKindMem             = "memstress"
+	stressKindCpu             = "cpustress"
+	stressKindStressNg        = "stressng"
+	stressKindCpuWork         = "cpu_work"
+	stressKindMemory          = "memory"
+	stressKindGoroutineLeak   = "goroutine_leak"
+	stressKindFileLeak        = "file_leak"
+	stressKindConnectionLeak  = "connection_leak"
+	simulatedErrorOnCall      = "error_on_call"
+	simulatedErrorCertificate = "certificate_expired"
+	simulatedErrorSpan        = "span_failed"
+)
+
+var (
+	endpointMetrics   *requestMetrics
+	routeMetrics      *requestMetrics
+	simulatedErrors   metric.Int64Counter
+	stressActivations metric.Int64Counter
+)
+
+// requestMetrics are the rate, errors and duration instruments of endpoints or routes.
+type requestMetrics struct {
+	requests metric.Int64Counter
+	errors   metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func initMetrics() {
+	endpointMetrics = newRequestMetrics("sim.endpoint", "endpoint")
+	routeMetrics = newRequestMetrics("sim.route", "route call")
+	simulatedErrors = newCounter("sim.errors.simulated", "Errors returned by error simulation.", "{error}")
+	stressActivations = newCounter("sim.stress.activations", "Stressors started and per request stress applied.", "{activation}")
+	_, err := otel.Meter.Int64ObservableCounter("sim.stressng.runs",
+		metric.WithDescription("Runs of the supervised stress-ng processes."),
+		metric.WithUnit("{run}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for kind, supervisor := range stressNgSupervisors() {
+				o.Observe(int64(supervisor.Status().Runs), metric.WithAttributes(attribute.String("sim.stress.kind", kind)))
+			}
+			return nil
+		}))
+	if err != nil {
+		slog.Error("failed to register metric", "name", "sim.stressng.runs", slog.Any("error", err))
+	}
+	_, err = otel.Meter.Int64ObservableGauge("sim.stressng.running",
+		metric.WithDescription("1 while a supervised stress-ng process is running, otherwise 0."),
+		metric.WithUnit("1"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			for kind, supervisor := range stressNgSupervisors() {
+				var running int64
+				if supervisor.Status().State == stress.StateRunning {
+					running = 1
+				}
+				o.Observe(running, metric.WithAttributes(attribute.String("sim.stress.kind", kind)))
+			}
+			return nil
+		}))
+	if err != nil {
+		slog.Error("failed to register metric", "name", "sim.stressng.running", slog.Any("error", err))
+	}
+	registerGauge("sim.memstress.target", "Bytes the memory stress pattern currently targets.", "By", func() int64 {
+		return int64(stress.MemStressTarget())
+	})
+	registerGauge("sim.memstress.allocated", "Bytes currently allocated by the memory stress.", "By", func() int64 {
+		return int64(stress.MemStressAllocated())
+	})
+	registerGauge("sim.memory.leaked", "Bytes retained by the endpoint memory leak pool.", "By", func() int64 {
+		return int64(stress.LeakedMemory())
+	})
+	registerGauge("sim.leak.goroutines", "Goroutines leaked by endpoints.", "{goroutine}", func() int64 {
+		return int64(stress.LeakedGoroutines())
+	})
+	registerGauge("sim.leak.files", "File descriptors leaked by endpoints.", "{file}", func() int64 {
+		return int64(stress.LeakedFiles())
+	})
+	registerGauge("sim.leak.connections", "Outbound route connections leaked by endpoints.", "{connection}", func() int64 {
+		return int64(stress.LeakedConnections())
+	})
+	registerCertificateMetrics()
+}
+
+func registerCertificateMetrics() {
+	_, err := otel.Meter.Float64ObservableGauge("sim.certificate.expiry",
+		metric.WithDescription("Days until the configured certificate expires, negative once expired."),
+		metric.WithUnit("d"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			if certMonitor != nil {
+				if days, ok := certMonitor.daysUntilExpiry(); ok {
+					o.Observe(days, metric.WithAttributes(attribute.String("sim.certificate.file", certMonitor.conf.CertFile)))
+				}
+			}
+			return nil
+		}))
+	if err != nil {
+		slog.Error("failed to register metric", "name", "sim.certificate.expiry", slog.Any("error", err))
+	}
+}
+
+func registerGauge(name, description, unit string, value func() int64) {
+	_, err := otel.Meter.Int64ObservableGauge(name,
+		metric.WithDescription(description),
+		metric.WithUnit(unit),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(value())
+			return nil
+		}))
+	if err != nil {
+		slog.Error("failed to register metric", "name", name, slog.Any("error", err))
+	}
+}
+
+// initPrometheus serves the metrics endpoint on the service mux when it shares the service port,
+// otherwise on its own listener that is closed when ctx is done.
+func initPrometheus(ctx context.Context, mux *http.ServeMux, conf *config.Configuration) {
+	prom := conf.OpenTelemetry.Prometheus
+	if !prom.Enabled || otel.PrometheusHandler == nil {
+		return
+	}
+	if prom.Port == conf.Port {
+		for i := range conf.Endpoints {
+			if conf.Endpoints[i].Uri == prom.Path {
+				slog.Warn("prometheus endpoint overridden by endpoint definition", "uri", prom.Path)
+				return
+			}
+		}
+		mux.Handle(prom.Path, otel.PrometheusHandler)
+		slog.Info("serving prometheus metrics", "path", prom.Path)
+		return
+	}
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle(prom.Path, otel.PrometheusHandler)
+	srv := &http.Server{Addr: fmt.Sprintf("%s:%d", conf.Address, prom.Port), Handler: metricsMux}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	go func() {
+		slog.Info("serving prometheus metrics", "address", srv.Addr, "path", prom.Path)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Error serving prometheus metrics", slog.Any("error", err))
+		}
+	}()
+}
+
+func newRequestMetrics(prefix, kind string) *requestMetrics {
+	requests, requestsErr := otel.Meter.Int64Counter(prefix+".requests",
+		metric.WithDescription(fmt.Sprintf("Number of %s requests.", kind)),
+		metric.WithUnit("{request}"))
+	failures, failuresErr := otel.Meter.Int64Counter(prefix+".errors",
+		metric.WithDescription(fmt.Sprintf("Number of failed %s requests.", kind)),
+		metric.WithUnit("{request}"))
+	duration, durationErr := otel.Meter.Float64Histogram(prefix+".duration",
+		metric.WithDescription(fmt.Sprintf("Duration of %s requests.", kind)),
+		metric.WithUnit("s"))
+	if err := errors.Join(requestsErr, failuresErr, durationErr); err != nil {
+		slog.Error("failed to register metrics", "prefix", prefix, slog.Any("error", err))
+		return nil
+	}
+	return &requestMetrics{requests: requests, errors: failures, duration: duration}
+}
+
+func (m *requestMetrics) record(ctx context.Context, duration time.Duration, failed bool, attrs ...attribute.KeyValue) {
+	if m == nil {
+		return
+	}
+	opt := metric.WithAttributes(attrs...)
+	m.requests.Add(ctx, 1, opt)
+	if failed {
+		m.errors.Add(ctx, 1, opt)
+	}
+	m.duration.Record(ctx, duration.Seconds(), opt)
+}
+
+// recordEndpoint records the RED metrics of an endpoint, responses with a 5xx status are errors.
+func recordEndpoint(ctx context.Context, endpoint *config.Endpoint, method string, status int, duration time.Duration) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(method),
+		semconv.HTTPRoute(endpoint.Uri),
+		semconv.HTTPResponseStatusCode(status),
+	}
+	failed := status >= http.StatusInternalServerError
+	if failed {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(status)))
+	}
+	endpointMetrics.record(ctx, duration, failed, attrs...)
+}
+
+// recordGrpcEndpoint records the RED metrics of a gRPC endpoint, every status other than OK is an error.
+func recordGrpcEndpoint(ctx context.Context, endpoint *config.Endpoint, code codes.Code, duration time.Duration) {
+	attrs := append(endpointAttributes(endpoint), semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	failed := code != codes.OK
+	if failed {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(code.String()))
+	}
+	endpointMetrics.record(ctx, duration, failed, attrs...)
+}
+
+// endpointAttributes identify an endpoint by its HTTP route, or by the rpc service and method of a gRPC endpoint.
+func endpointAttributes(endpoint *config.Endpoint) []attribute.KeyValue {
+	if method, ok := grpcMethods[endpoint.Uri]; ok {
+		return []attribute.KeyValue{semconv.RPCSystemGRPC, semconv.RPCService(method.service), semconv.RPCMethod(method.method)}
+	}
+	return []attribute.KeyValue{semconv.HTTPRoute(endpoint.Uri)}
+}
+
+// recordRoute records the RED metrics of a route call, failed calls and responses with a 4xx or 5xx status are errors.
+func recordRoute(ctx context.Context, route *config.Route, resp *http.Response, err error, duration time.Duration) {
+	attrs := []attribute.KeyValue{
+		attribute.String("sim.route", route.Uri),
+		semconv.HTTPRequestMethodKey.String(http.MethodGet),
+	}
+	host, _, _ := strings.Cut(route.Uri, "/")
+	if address, port, err := net.SplitHostPort(host); err == nil {
+		attrs = append(attrs, semconv.ServerAddress(address))
+		if p, err := strconv.Atoi(port); err == nil {
+			attrs = append(attrs, semconv.ServerPort(p))
+		}
+	} else {
+		attrs = append(attrs, semconv.ServerAddress(host))
+	}
+	failed := err != nil
+	if err != nil {
+		attrs = append(attrs, semconv.ErrorTypeKey.String(errorType(err)))
+	} else {
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= http.StatusBadRequest {
+			failed = true
+			attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+		}
+	}
+	routeMetrics.record(ctx, duration, failed, attrs...)
+}
+
+// errorType describes why a route call failed, e.g. "timeout" or "*net.OpError".
+func errorType(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	return fmt.Sprintf("%T", err)
+}
+
+func countSimulatedError(ctx context.Context, endpoint *config.Endpoint, kind string) {
+	if simulatedErrors != nil {
+		simulatedErrors.Add(ctx, 1, metric.WithAttributes(
+			append(endpointAttributes(endpoint), attribute.String("sim.error.kind", kind))...,
+		))
+	}
+}
+
+// countStressActivation counts started stressors and stress applied per request, which is attributed to the endpoint.
+func countStressActivation(ctx context.Context, kind string, attrs ...attribute.KeyValue) {
+	if stressActivations != nil {
+		stressActivations.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.String("sim.stress.kind", kind))...))
+	}
+}
+
+func newCounter(name, description, unit string) metric.Int64Counter {
+	counter, err := otel.Meter.Int64Counter(name, metric.WithDescription(description), metric.WithUnit(unit))
+	if err != nil {
+		slog.Error("failed to register metric", "name", name, slog.Any("error", err))
+		return nil
+	}
+	return counter
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/ravan/microservice-sim/internal/otel"
+	"github.com/ravan/microservice-sim/internal/stress"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrometheus(t *testing.T) {
+	conf, err := config.GetConfig("")
+	require.NoError(t, err)
+	conf.OpenTelemetry.Prometheus.Enabled = true
+	conf.OpenTelemetry.Prometheus.Port = conf.Port
+	shutdown, err := otel.InitializeOpenTelemetry(context.Background(), conf.OpenTelemetry)
+	require.NoError(t, err)
+	defer func() { _ = shutdown(context.Background()) }()
+	otel.NewMeter()
+	registerGauge("sim.test.value", "Test gauge.", "{item}", func() int64 { return 42 })
+
+	mux := http.NewServeMux()
+	initPrometheus(context.Background(), mux, conf)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "sim_test_value")
+	require.Contains(t, string(body), "} 42")
+}
+
+func TestRequestMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.Meter = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	initMetrics()
+	defer func() {
+		endpointMetrics, routeMetrics, simulatedErrors, stressActivations = nil, nil, nil, nil
+	}()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer backend.Close()
+	mux := http.NewServeMux()
+	initEndpoints(mux, []config.Endpoint{
+		{Uri: "/ok", CpuWork: "100", Routes: []config.Route{{Uri: strings.TrimPrefix(backend.URL, "http://") + "/missing"}}},
+		{Uri: "/fail", ErrorOnCall: 1},
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	for _, uri := range []string{"/ok", "/ok", "/fail"} {
+		resp, err := http.Get(srv.URL + uri)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	sums := map[string]int64{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			for _, dp := range data.DataPoints {
+				route, _ := dp.Attributes.Value("http.route")
+				sums[m.Name+" "+route.AsString()] += dp.Value
+			}
+		case metricdata.Histogram[float64]:
+			for _, dp := range data.DataPoints {
+				sums[m.Name] += int64(dp.Count)
+			}
+		}
+	}
+	require.Equal(t, int64(2), sums["sim.endpoint.requests /ok"])
+	require.Equal(t, int64(1), sums["sim.endpoint.requests /fail"])
+	require.Equal(t, int64(0), sums["sim.endpoint.errors /ok"])
+	require.Equal(t, int64(1), sums["sim.endpoint.errors /fail"])
+	require.Equal(t, int64(3), sums["sim.endpoint.duration"])
+	require.Equal(t, int64(2), sums["sim.route.requests "])
+	require.Equal(t, int64(2), sums["sim.route.errors "])
+	require.Equal(t, int64(2), sums["sim.route.duration"])
+	require.Equal(t, int64(1), sums["sim.errors.simulated /fail"])
+	require.Equal(t, int64(2), sums["sim.stress.activations /ok"])
+}
+
+func TestStressNgMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.Meter = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	initMetrics()
+	stressNg, cpuStressNg = &stress.StressNg{}, &stress.StressNg{}
+	defer func() {
+		endpointMetrics, routeMetrics, simulatedErrors, stressActivations = nil, nil, nil, nil
+		stressNg, cpuStressNg = nil, nil
+	}()
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	kinds := map[string][]string{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		var points []metricdata.DataPoint[int64]
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			points = data.DataPoints
+		case metricdata.Gauge[int64]:
+			points = data.DataPoints
+		}
+		for _, dp := range points {
+			if kind, ok := dp.Attributes.Value("sim.stress.kind"); ok {
+				kinds[m.Name] = append(kinds[m.Name], kind.AsString())
+			}
+		}
+	}
+	require.ElementsMatch(t, []string{stressKindStressNg, stressKindCpu}, kinds["sim.stressng.runs"])
+	require.ElementsMatch(t, []string{stressKindStressNg, stressKindCpu}, kinds["sim.stressng.running"])
+
+	rec := httptest.NewRecorder()
+	healthHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Contains(t, rec.Body.String(), `"cpustress":{"state":"waiting"`)
+	require.Contains(t, rec.Body.String(), `"stressng":{"state":"waiting"`)
+}
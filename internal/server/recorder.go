@@ -0,0 +1,40 @@
+package server
+
+import (
+	"github.com/dustin/go-humanize"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/ravan/microservice-sim/internal/record"
+	"log/slog"
+	"net/http"
+	"slices"
+)
+
+// initRecorder returns a recorder appending incoming requests to the configured file, except for skipPaths.
+func initRecorder(conf *config.Recorder, skipPaths ...string) *record.Recorder {
+	if !conf.Enabled {
+		return nil
+	}
+	maxBodySize, err := humanize.ParseBytes(conf.MaxBodySize)
+	if err != nil {
+		slog.Error("Error parsing recorder max body size", "maxBodySize", conf.MaxBodySize, "error", err)
+		return nil
+	}
+	recorder, err := record.NewRecorder(conf.File, int64(maxBodySize), func(r *http.Request) bool {
+		return slices.Contains(skipPaths, r.URL.Path)
+	})
+	if err != nil {
+		slog.Error("Error opening recorder file", "file", conf.File, "error", err)
+		return nil
+	}
+	slog.Info("recording requests", "file", conf.File)
+	return recorder
+}
+
+// recorderSkipPaths excludes health checks and metric scrapes from recording.
+func recorderSkipPaths(conf *config.Configuration) []string {
+	paths := []string{conf.HealthUri}
+	if prom := conf.OpenTelemetry.Prometheus; prom.Enabled && prom.Port == conf.Port {
+		paths = append(paths, prom.Path)
+	}
+	return paths
+}
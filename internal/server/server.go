@@ -2,54 +2,67 @@ package server
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ravan/microservice-sim/internal/config"
 	"github.com/ravan/microservice-sim/internal/otel"
 	"github.com/ravan/microservice-sim/internal/stress"
 	"github.com/ravan/microservice-sim/internal/util"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
-	"log"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 var errorCounters = make(map[string]*util.Counter)
+var memAllocators = make(map[string]*stress.RequestMem)
+var leakRates = make(map[string]*endpointLeakRates)
 var client = &http.Client{}
 var otelActive = false
 var serviceName = "service-sim"
 var envVars map[string]string
-
-const (
-	traceParent = "traceparent"
-	traceState  = "tracestate"
-)
+var stressNg *stress.StressNg
+var cpuStressNg *stress.StressNg
+var stressors sync.WaitGroup
 
 func Run(conf *config.Configuration) error {
-	setDefaultLogLevel(conf.LogLevel)
+	setDefaultLogLevel(conf.LogLevel, &conf.TraceLog)
 	envVars = getEnvironmentVars()
-	otelActive = conf.OpenTelemetry.Trace.Enabled || conf.OpenTelemetry.Metrics.Enabled
+	otelActive = conf.OpenTelemetry.Trace.Enabled || conf.OpenTelemetry.Metrics.Enabled || conf.OpenTelemetry.Logs.Enabled ||
+		conf.OpenTelemetry.Prometheus.Enabled
 	serviceName = conf.ServiceName
 
+	if err := stress.SetResourceBasis(conf.Resources.Basis, conf.Resources.MemoryRequest, conf.Resources.CpuRequest); err != nil {
+		return err
+	}
+	stress.LogResourceBasis()
+
 	//perform crashback loop
 	if conf.Certificate.Enabled {
-		conf.Certificate.GetDelayDuration().ApplyBefore(serviceName, "self")
+		conf.Certificate.GetDelayDuration().ApplyBefore(context.Background(), serviceName, "self")
 		err := validateCertAndKey(conf.Certificate.CertFile, conf.Certificate.KeyFile)
-		if err != nil {
+		if err != nil && conf.Certificate.ExitOnExpired {
 			slog.Error("invalid certificate", slog.Any("error", err))
 			os.Exit(1)
 		}
 	}
 
+	initCrash(&conf.Crash)
+
 	ctx := context.Background()
+	stopCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	if otelActive {
 		shutdown, err := otel.InitializeOpenTelemetry(ctx, conf.OpenTelemetry)
 		if err != nil {
@@ -61,20 +74,36 @@ func Run(conf *config.Configuration) error {
 				slog.Error("Error shutting down otel:", slog.Any("error", err))
 			}
 		}(ctx)
+		if otel.LogHandler != nil {
+			setDefaultLogLevel(conf.LogLevel, &conf.TraceLog, otel.LogHandler)
+		}
 		otel.NewTracer(conf.OpenTelemetry)
+		otel.NewMeter()
+		initMetrics()
 	}
+	initCertificateMonitor(stopCtx, &conf.Certificate)
 	addr := fmt.Sprintf("%s:%d", conf.Address, conf.Port)
 
 	mux := http.NewServeMux()
 
 	initEndpoints(mux, conf.Endpoints)
+	initHealth(mux, conf.HealthUri, conf.Endpoints)
+	initPrometheus(stopCtx, mux, conf)
+	if err := initGrpc(stopCtx, conf.Address, &conf.Grpc); err != nil {
+		return err
+	}
 	go initMemStress(&conf.MemStress)
-	go initStressNg(&conf.StressNg)
+	initStressNg(stopCtx, &conf.StressNg)
+	initCpuStress(stopCtx, &conf.CpuStress)
 	data := getDataMap()
-	conf.Logging.LogBefore(data)
-	conf.Logging.LogAfter(data)
+	conf.Logging.LogBefore(stopCtx, data)
+	conf.Logging.LogAfter(stopCtx, data)
 
-	var handler http.Handler
+	var handler http.Handler = mux
+	if recorder := initRecorder(&conf.Recorder, recorderSkipPaths(conf)...); recorder != nil {
+		defer recorder.Close()
+		handler = recorder.Middleware(handler)
+	}
 	if otelActive {
 		httpSpanName := func(operation string, r *http.Request) string {
 			uri := strings.Replace(r.URL.Path, "/", ".", -1)
@@ -84,16 +113,32 @@ func Run(conf *config.Configuration) error {
 			return uri
 		}
 		handler = otelhttp.NewHandler(
-			mux,
+			handler,
 			"/",
 			otelhttp.WithSpanNameFormatter(httpSpanName),
 		)
-	} else {
-		handler = mux
 	}
 
+	srv := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		<-stopCtx.Done()
+		slog.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Error shutting down server", slog.Any("error", err))
+		}
+	}()
+
 	slog.Info("Listening on", slog.String("address", addr))
-	return http.ListenAndServe(addr, handler)
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+	// terminate child processes like stress-ng before exiting
+	stop()
+	stressors.Wait()
+	return err
 }
 
 func getDataMap() map[string]interface{} {
@@ -105,49 +150,105 @@ func getDataMap() map[string]interface{} {
 func initEndpoints(mux *http.ServeMux, endpoints []config.Endpoint) {
 	for i := range endpoints {
 		endpoint := &endpoints[i]
-		errorCounters[endpoint.Uri] = &util.Counter{
-			TriggerOn: endpoint.ErrorOnCall,
-			Active:    endpoint.ErrorOnCall > 0,
-		}
+		registerEndpoint(endpoint)
 		mux.HandleFunc(endpoint.Uri, func(w http.ResponseWriter, r *http.Request) {
 			endpointHandler(endpoint, w, r)
 		})
 	}
 }
 
-func endpointHandler(endpoint *config.Endpoint, w http.ResponseWriter, r *http.Request) {
+// registerEndpoint prepares the error simulation and memory allocation of an HTTP or gRPC endpoint.
+func registerEndpoint(endpoint *config.Endpoint) {
+	errorCounters[endpoint.Uri] = &util.Counter{
+		TriggerOn: endpoint.ErrorOnCall,
+		Active:    endpoint.ErrorOnCall > 0,
+	}
+	if endpoint.Memory.Allocate != "" {
+		mem, err := stress.NewRequestMem(endpoint.Memory.Allocate, endpoint.Memory.Leak, endpoint.Memory.LeakCap)
+		if err != nil {
+			slog.Error("failed to parse endpoint memory", "endpoint", endpoint.Uri, slog.Any("error", err))
+		} else {
+			memAllocators[endpoint.Uri] = mem
+		}
+	}
+	leakRates[endpoint.Uri] = &endpointLeakRates{
+		goroutines:  leakRate(endpoint.Leaks.Goroutines),
+		files:       leakRate(endpoint.Leaks.Files),
+		connections: leakRate(endpoint.Leaks.Connections),
+	}
+}
+
+func endpointHandler(endpoint *config.Endpoint, rw http.ResponseWriter, r *http.Request) {
 	data := getDataMap()
 	data["Endpoint"] = endpoint
+	data["Request"] = r
 	ctx := r.Context()
-
-	if handleErrorSimulation(endpoint, w, data) {
+	start := time.Now()
+	w := util.NewStatusWriter(rw)
+	defer func() {
+		recordEndpoint(ctx, endpoint, r.Method, w.Status(), time.Since(start))
+	}()
+
+	checkCrash(endpoint)
+	annotateSpan(ctx, &endpoint.SpanAnnotations, data)
+	if err := checkCertificate(ctx, endpoint); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		writeErrorResponseBody(w, err)
 		return
 	}
-	endpoint.Logging.LogBefore(data)
+	if handleErrorSimulation(ctx, endpoint, w, data) {
+		return
+	}
+	endpoint.Logging.LogBefore(ctx, data)
 	handleEndpoint(&ctx, endpoint, w, r)
-	endpoint.Logging.LogAfter(data)
+	endpoint.Logging.LogAfter(ctx, data)
+}
+
+// checkCertificate adds the certificate expiry to the request span and fails the request once an expired
+// certificate fails requests.
+func checkCertificate(ctx context.Context, endpoint *config.Endpoint) error {
+	if certMonitor == nil {
+		return nil
+	}
+	if days, ok := certMonitor.daysUntilExpiry(); ok {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Float64("sim.certificate.days_until_expiry", days))
+	}
+	if certMonitor.failing(FailOnExpiryRequests) {
+		countSimulatedError(ctx, endpoint, simulatedErrorCertificate)
+		slog.ErrorContext(ctx, "request failed, certificate expired", "endpoint", endpoint.Uri)
+		return fmt.Errorf("certificate expired")
+	}
+	return nil
+}
+
+func handleErrorSimulation(ctx context.Context, endpoint *config.Endpoint, w http.ResponseWriter, data map[string]interface{}) bool {
+	if simErr := simulateError(ctx, endpoint, data); simErr != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		writeErrorResponseBody(w, simErr)
+		return true
+	}
+	return false
 }
 
-func handleErrorSimulation(endpoint *config.Endpoint, w http.ResponseWriter, data map[string]interface{}) bool {
+// simulateError returns the simulated error on every errorOnCall-th call of the endpoint.
+func simulateError(ctx context.Context, endpoint *config.Endpoint, data map[string]interface{}) error {
 	counter := errorCounters[endpoint.Uri]
 	if counter.Active {
 		counter.Increment()
 		if counter.ShouldTrigger() {
-			w.WriteHeader(http.StatusInternalServerError)
+			countSimulatedError(ctx, endpoint, simulatedErrorOnCall)
 			errMsg := endpoint.ErrorLogging.GetLogBeforeMsg(data)
 			if errMsg == "" {
 				errMsg = fmt.Sprintf("error while processing: %s", endpoint.Uri)
 			}
 
-			simErr := fmt.Errorf(errMsg)
-			writeErrorResponseBody(w, simErr)
 			counter.Reset()
-			slog.Error(errMsg)
-			slog.Debug("err simulation", "triggered-nth-call", counter.TriggerOn)
-			return true
+			slog.ErrorContext(ctx, errMsg)
+			slog.DebugContext(ctx, "err simulation", "triggered-nth-call", counter.TriggerOn)
+			return errors.New(errMsg)
 		}
 	}
-	return false
+	return nil
 }
 
 func writeErrorResponseBody(w http.ResponseWriter, simErr error) {
@@ -165,31 +266,71 @@ func handleEndpoint(ctx *context.Context, endpoint *config.Endpoint, w http.Resp
 		return
 	}
 	data := getDataMap()
+	data["Endpoint"] = endpoint
+	data["Request"] = r
 
-	slog.Debug(fmt.Sprintf("%s %s", r.Method, r.URL.Path))
-	endpoint.GetDelayDuration().ApplyBefore("routing", "self")
+	slog.DebugContext(*ctx, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+	if err := processEndpoint(ctx, endpoint, data); err != nil {
+		setupInternalServerError(w, err)
+		return
+	}
+	writeSuccessResponseBody(endpoint, w)
+}
+
+// processEndpoint applies the delay, stress, leaks, spans and routes of an HTTP or gRPC endpoint.
+// It returns the error of a failed span or of a failed route that stops on failure.
+func processEndpoint(ctx *context.Context, endpoint *config.Endpoint, data map[string]interface{}) error {
+	endpoint.GetDelayDuration().ApplyBefore(*ctx, "routing", "self")
+	endpointAttrs := endpointAttributes(endpoint)
+	if cpuWork := endpoint.GetCpuWork(); cpuWork.Enabled {
+		cpuWork.Apply(*ctx, "routing", "self")
+		countStressActivation(*ctx, stressKindCpuWork, endpointAttrs...)
+	}
+	if mem, ok := memAllocators[endpoint.Uri]; ok {
+		mem.Apply()
+		countStressActivation(*ctx, stressKindMemory, endpointAttrs...)
+	}
+	leaks := leakRates[endpoint.Uri]
+	applyResourceLeaks(*ctx, endpoint, leaks)
+	if err := runSpans(*ctx, endpoint.Spans); err != nil {
+		countSimulatedError(*ctx, endpoint, simulatedErrorSpan)
+		slog.ErrorContext(*ctx, "request failed", "endpoint", endpoint.Uri, slog.String("error", err.Error()))
+		return err
+	}
+	var connectionLeak *stress.LeakRate
+	if leaks != nil {
+		connectionLeak = leaks.connections
+	}
+	leakedConnections := 0
 	if len(endpoint.Routes) > 0 {
 		for i := range endpoint.Routes {
 			route := &endpoint.Routes[i]
 			data["Route"] = route
-			route.Logging.LogBefore(data)
-			err := handleRoute(ctx, route)
+			route.Logging.LogBefore(*ctx, data)
+			resp, err := handleRoute(ctx, route, data)
+			if resp != nil {
+				if connectionLeak != nil && leakedConnections < connectionLeak.PerRequest && stress.LeakConnection(connectionLeak, resp.Body) {
+					leakedConnections++
+					countStressActivation(*ctx, stressKindConnectionLeak, endpointAttrs...)
+				} else {
+					closeResponse(resp)
+				}
+			}
 			if err != nil && route.StopOnFail {
-				setupInternalServerError(w, err)
-				slog.Error("Error when calling.", "target", route.Uri, slog.String("error", err.Error()))
-				return
+				slog.ErrorContext(*ctx, "Error when calling.", "target", route.Uri, slog.String("error", err.Error()))
+				return err
 			}
-			route.Logging.LogAfter(data)
+			route.Logging.LogAfter(*ctx, data)
 		}
 	} else {
-		slog.Debug("no routes defined")
+		slog.DebugContext(*ctx, "no routes defined")
 	}
 
-	endpoint.GetDelayDuration().ApplyAfter("routing", "self")
-	writeSuccessResponseBody(endpoint, w)
+	endpoint.GetDelayDuration().ApplyAfter(*ctx, "routing", "self")
+	return nil
 }
 
-func writeSuccessResponseBody(endpoint *config.Endpoint, w http.ResponseWriter) {
+func successResponseBody(endpoint *config.Endpoint) map[string]interface{} {
 	body := map[string]interface{}{
 		"success": true,
 	}
@@ -198,7 +339,11 @@ func writeSuccessResponseBody(endpoint *config.Endpoint, w http.ResponseWriter)
 			body[k] = v
 		}
 	}
-	b, err := json.Marshal(body)
+	return body
+}
+
+func writeSuccessResponseBody(endpoint *config.Endpoint, w http.ResponseWriter) {
+	b, err := json.Marshal(successResponseBody(endpoint))
 	if err != nil {
 		setupInternalServerError(w, err)
 	} else {
@@ -213,40 +358,76 @@ func setupInternalServerError(w http.ResponseWriter, err error) {
 	http.Error(w, err.Error(), http.StatusInternalServerError)
 }
 
-func handleRoute(ctx *context.Context, route *config.Route) error {
+func handleRoute(ctx *context.Context, route *config.Route, data map[string]interface{}) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(*ctx, "GET", fmt.Sprintf("http://%s", route.Uri), nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var span trace.Span
+	callCtx := *ctx
 	if otelActive {
-		var newCtx context.Context
-		newCtx, span = otel.Tracer.Start(*ctx, strings.ReplaceAll(route.Uri, "/", "."))
+		callCtx, span = otel.Tracer.Start(*ctx, strings.ReplaceAll(route.Uri, "/", "."))
 		defer span.End()
+		annotateSpan(callCtx, &route.SpanAnnotations, data)
+		otel.InjectHeaders(callCtx, req.Header)
+	}
+	route.GetDelayDuration().ApplyBefore(callCtx, "route-call", route.Uri)
+	route.GetCpuWork().Apply(callCtx, "route-call", route.Uri)
+	slog.DebugContext(callCtx, "calling", "target", route.Uri)
+	start := time.Now()
+	resp, err := client.Do(req)
+	recordRoute(*ctx, route, resp, err, time.Since(start))
+	slog.DebugContext(callCtx, "returned", "target", route.Uri, slog.Any("error", err))
+	route.GetDelayDuration().ApplyAfter(callCtx, "route-call", route.Uri)
 
-		tc := propagation.TraceContext{}
-		mc := propagation.MapCarrier{}
+	if otelActive && err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return resp, err
+}
+
+func closeResponse(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
 
-		tc.Inject(newCtx, mc)
+// endpointLeakRates are the resource leaks of an endpoint, kept across requests so their caps apply per endpoint.
+type endpointLeakRates struct {
+	goroutines  *stress.LeakRate
+	files       *stress.LeakRate
+	connections *stress.LeakRate
+}
 
-		if _, ok := mc[traceParent]; ok && mc[traceParent] != "" {
-			req.Header.Add(traceParent, mc.Get(traceParent))
-		}
-		if _, ok := mc[traceState]; ok {
-			req.Header.Add(traceState, mc.Get(traceState))
+func applyResourceLeaks(ctx context.Context, endpoint *config.Endpoint, leaks *endpointLeakRates) {
+	if leaks == nil {
+		return
+	}
+	if rate := leaks.goroutines; rate != nil {
+		stress.LeakGoroutines(rate)
+		countStressActivation(ctx, stressKindGoroutineLeak, endpointAttributes(endpoint)...)
+	}
+	if rate := leaks.files; rate != nil {
+		if err := stress.LeakFiles(rate); err != nil {
+			slog.ErrorContext(ctx, "failed to leak file", "endpoint", endpoint.Uri, slog.Any("error", err))
+		} else {
+			countStressActivation(ctx, stressKindFileLeak, endpointAttributes(endpoint)...)
 		}
 	}
-	route.GetDelayDuration().ApplyBefore("route-call", route.Uri)
-	slog.Debug("calling", "target", route.Uri)
-	_, err = client.Do(req)
-	slog.Debug("returned", "target", route.Uri, slog.Any("error", err))
-	route.GetDelayDuration().ApplyAfter("route-call", route.Uri)
+}
 
-	if otelActive && err != nil {
-		span.RecordError(err)
-		span.SetStatus(codes.Error, err.Error())
+func leakRate(leak config.LeakStress) *stress.LeakRate {
+	if !leak.Enabled {
+		return nil
+	}
+	perRequest := leak.PerRequest
+	if perRequest <= 0 {
+		perRequest = 1
+	}
+	return &stress.LeakRate{
+		PerRequest: perRequest,
+		Cap:        leak.Cap,
 	}
-	return err
 }
 
 func initMemStress(conf *config.MemStress) {
@@ -260,38 +441,180 @@ func initMemStress(conf *config.MemStress) {
 				time.Sleep(startDelay)
 			}
 		}
-		slog.Info("stressing memory", "size", conf.MemSize, "timing", conf.GrowthTime)
-		duration, err := time.ParseDuration(conf.GrowthTime)
-		if err != nil {
-			slog.Error("failed to parse duration", slog.Any("error", err))
-			os.Exit(1)
-		} else {
-			err = stress.Mem(conf.MemSize, duration)
-			if err != nil {
-				slog.Error("failed to stress memory", slog.Any("error", err))
+		slog.Info("stressing memory", "pattern", conf.Pattern, "size", conf.MemSize, "timing", conf.GrowthTime)
+		countStressActivation(context.Background(), stressKindMem)
+		opts := stress.MemOptions{
+			Pattern:  conf.Pattern,
+			MemSize:  conf.MemSize,
+			MinSize:  conf.MinSize,
+			StepSize: conf.StepSize,
+		}
+		durations := []struct {
+			value  string
+			target *time.Duration
+		}{
+			{conf.GrowthTime, &opts.GrowthTime},
+			{conf.Period, &opts.Period},
+			{conf.StepInterval, &opts.StepInterval},
+			{conf.HoldTime, &opts.HoldTime},
+		}
+		for _, d := range durations {
+			var err error
+			if *d.target, err = parseOptionalDuration(d.value); err != nil {
+				slog.Error("failed to parse duration", slog.Any("error", err))
 				os.Exit(1)
 			}
 		}
+		err := stress.Mem(opts)
+		if err != nil {
+			slog.Error("failed to stress memory", slog.Any("error", err))
+			os.Exit(1)
+		}
 	}
 }
 
-func initStressNg(conf *config.StressNg) {
-	if conf.Enabled {
+func initStressNg(ctx context.Context, conf *config.StressNg) {
+	if !conf.Enabled {
+		return
+	}
+	duration, err := parseOptionalDuration(conf.Duration)
+	if err != nil {
+		slog.Error("failed to parse stress duration", "duration", conf.Duration, slog.Any("error", err))
+		return
+	}
+	repeat, err := parseOptionalDuration(conf.Repeat)
+	if err != nil {
+		slog.Error("failed to parse stress repeat interval", "repeat", conf.Repeat, slog.Any("error", err))
+		return
+	}
+	stressNg = &stress.StressNg{
+		Args:        conf.Args,
+		Duration:    duration,
+		Repeat:      repeat,
+		OutputLevel: parseLogLevel(conf.OutputLevel),
+	}
+	stressors.Add(1)
+	go func() {
+		defer stressors.Done()
 		if conf.Delay != "" {
 			startDelay, err := time.ParseDuration(conf.Delay)
 			if err != nil {
 				slog.Error("Error parsing stress start delay", "delay", conf.Delay, "error", err)
 			} else {
 				slog.Debug("stress start delay.", "delay", startDelay)
-				time.Sleep(startDelay)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(startDelay):
+				}
 			}
 		}
-		slog.Info("stressing", "args", strings.Join(conf.Args, ", "))
-		stress.Stress(conf.Args)
+		slog.Info("stressing", "args", strings.Join(conf.Args, ", "), "duration", conf.Duration, "repeat", conf.Repeat)
+		countStressActivation(ctx, stressKindStressNg)
+		if err := stressNg.Run(ctx); err != nil {
+			slog.Error("stress-ng failed", slog.Any("error", err))
+		}
+	}()
+}
+
+// initCpuStress starts the cpu stressor. The stress-ng backend runs in its own process group, so it is stopped
+// when ctx is done and waited for on shutdown; the native stressor ends with the service.
+func initCpuStress(ctx context.Context, conf *config.CpuStress) {
+	if !conf.Enabled {
+		return
+	}
+	load, err := strconv.ParseFloat(strings.TrimSuffix(conf.Load, "%"), 64)
+	if err != nil {
+		slog.Error("failed to parse cpu stress load", "load", conf.Load, slog.Any("error", err))
+		return
+	}
+	duration, err := parseOptionalDuration(conf.Duration)
+	if err != nil {
+		slog.Error("failed to parse cpu stress duration", "duration", conf.Duration, slog.Any("error", err))
+		return
+	}
+	rampTime, err := parseOptionalDuration(conf.RampTime)
+	if err != nil {
+		slog.Error("failed to parse cpu stress ramp time", "rampTime", conf.RampTime, slog.Any("error", err))
+		return
+	}
+	stressNgBackend := conf.Backend == "stress-ng"
+	if stressNgBackend {
+		cpuStressNg = &stress.StressNg{
+			Args:        stress.CpuStressNgArgs(load, duration),
+			OutputLevel: slog.LevelDebug,
+		}
+		stressors.Add(1)
 	}
+	go func() {
+		if stressNgBackend {
+			defer stressors.Done()
+		}
+		if conf.Delay != "" {
+			startDelay, err := time.ParseDuration(conf.Delay)
+			if err != nil {
+				slog.Error("Error parsing cpu stress start delay", "delay", conf.Delay, "error", err)
+			} else {
+				slog.Debug("cpu stress start delay.", "delay", startDelay)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(startDelay):
+				}
+			}
+		}
+		slog.Info("stressing cpu", "load", conf.Load, "duration", conf.Duration, "rampTime", conf.RampTime, "backend", conf.Backend)
+		countStressActivation(ctx, stressKindCpu, attribute.String("sim.stress.backend", conf.Backend))
+		if stressNgBackend {
+			if rampTime > 0 {
+				slog.Warn("cpu stress ramp time is not supported by stress-ng backend")
+			}
+			if err := cpuStressNg.Run(ctx); err != nil {
+				slog.Error("cpu stress failed", slog.Any("error", err))
+			}
+		} else {
+			stress.Cpu(load, duration, rampTime)
+		}
+	}()
+}
+
+// stressNgSupervisors returns the started stress-ng supervisors by the stress kind that started them.
+func stressNgSupervisors() map[string]*stress.StressNg {
+	supervisors := make(map[string]*stress.StressNg)
+	if stressNg != nil {
+		supervisors[stressKindStressNg] = stressNg
+	}
+	if cpuStressNg != nil {
+		supervisors[stressKindCpu] = cpuStressNg
+	}
+	return supervisors
+}
+
+func parseOptionalDuration(duration string) (time.Duration, error) {
+	if duration == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(duration)
+}
+
+// setDefaultLogLevel logs to stderr and, when given, to exporters like the OpenTelemetry log bridge.
+// Stderr lines carry the ids of the active span when trace logging is enabled.
+func setDefaultLogLevel(stringLevel string, traceLog *config.TraceLog, exporters ...slog.Handler) {
+	level := parseLogLevel(stringLevel)
+	var handler slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+	})
+	if traceLog != nil && traceLog.Enabled {
+		handler = otel.NewTraceContextHandler(handler, traceLog.Convention, traceLog.TraceIdKey, traceLog.SpanIdKey)
+	}
+	if len(exporters) > 0 {
+		handler = otel.NewFanoutHandler(level, append([]slog.Handler{handler}, exporters...)...)
+	}
+	slog.SetDefault(slog.New(handler))
+
 }
 
-func setDefaultLogLevel(stringLevel string) {
+func parseLogLevel(stringLevel string) slog.Level {
 	level := slog.LevelInfo
 	switch strings.ToLower(stringLevel) {
 	case "debug":
@@ -303,12 +626,7 @@ func setDefaultLogLevel(stringLevel string) {
 	case "error":
 		level = slog.LevelError
 	}
-
-	handler := slog.NewTextHandler(log.Writer(), &slog.HandlerOptions{
-		Level: level,
-	})
-	slog.SetDefault(slog.New(handler))
-
+	return level
 }
 
 func getEnvironmentVars() map[string]string {
@@ -319,35 +637,3 @@ func getEnvironmentVars() map[string]string {
 	}
 	return items
 }
-func validateCertAndKey(certPath, keyPath string) error {
-	// Read the certificate file
-	certPEM, err := os.ReadFile(certPath)
-	if err != nil {
-		return fmt.Errorf("failed to read certificate file: %v", err)
-	}
-
-	// Read the key file
-	keyPEM, err := os.ReadFile(keyPath)
-	if err != nil {
-		return fmt.Errorf("failed to read key file: %v", err)
-	}
-
-	// Load the certificate
-	cert, err := tls.X509KeyPair(certPEM, keyPEM)
-	if err != nil {
-		return fmt.Errorf("failed to load certificate and key pair: %v", err)
-	}
-
-	// Parse the certificate to ensure it is valid
-	certParsed, err := x509.ParseCertificate(cert.Certificate[0])
-	if err != nil {
-		return fmt.Errorf("failed to parse certificate: %v", err)
-	}
-
-	// Give a vague error because this is used with the certificate expired observability scenario
-	if certParsed.NotAfter.Before(time.Now()) {
-		return fmt.Errorf("certificate error")
-	}
-
-	return nil
-}
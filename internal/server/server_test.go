@@ -3,15 +3,23 @@ package server
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/ravan/microservice-sim/internal/stress"
 	"github.com/ravan/microservice-sim/internal/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"text/template"
 	"time"
@@ -115,6 +123,57 @@ func TestStressNg(t *testing.T) {
 	time.Sleep(20 * time.Second)
 }
 
+func TestCpuStressNgStopsOnShutdown(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "pid")
+	script := "#!/bin/sh\necho $$ > " + pidFile + "\nexec sleep 30\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stress-ng"), []byte(script), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	ctx, stop := context.WithCancel(context.Background())
+	initCpuStress(ctx, &config.CpuStress{Enabled: true, Backend: "stress-ng", Load: "10%"})
+	defer func() { cpuStressNg = nil }()
+	var pid int
+	require.Eventually(t, func() bool {
+		b, err := os.ReadFile(pidFile)
+		pid, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+		return err == nil && pid > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		return cpuStressNg.Status().State == stress.StateRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	stop()
+	stressors.Wait()
+	require.ErrorIs(t, syscall.Kill(pid, 0), syscall.ESRCH)
+	require.Equal(t, stress.StateStopped, cpuStressNg.Status().State)
+}
+
+func TestSetDefaultLogLevelTwice(t *testing.T) {
+	defaultLogger := slog.Default()
+	stderr := os.Stderr
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stderr = w
+	defer func() {
+		os.Stderr = stderr
+		slog.SetDefault(defaultLogger)
+	}()
+
+	setDefaultLogLevel("debug", nil)
+	setDefaultLogLevel("debug", nil, slog.NewTextHandler(io.Discard, nil))
+	slog.Debug("twice")
+	require.NoError(t, w.Close())
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	require.Len(t, lines, 1)
+	require.Contains(t, lines[0], "level=DEBUG msg=twice")
+	require.Equal(t, 1, strings.Count(lines[0], "time="))
+}
+
 // Launch Jager
 // docker run --rm --name jaeger -e COLLECTOR_ZIPKIN_HOST_PORT=:9411 -p 16686:16686 -p 4318:4318 jaegertracing/all-in-one:latest
 // http://localhost:16686
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/ravan/microservice-sim/internal/otel"
+	"github.com/ravan/microservice-sim/internal/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+const (
+	spanPresetDb        = "db"
+	spanPresetCache     = "cache"
+	spanPresetMessaging = "messaging"
+)
+
+var spanKinds = map[string]trace.SpanKind{
+	"internal": trace.SpanKindInternal,
+	"client":   trace.SpanKindClient,
+	"server":   trace.SpanKindServer,
+	"producer": trace.SpanKindProducer,
+	"consumer": trace.SpanKindConsumer,
+}
+
+// runSpans records the synthetic spans of an endpoint as children of the span in ctx. Without tracing the
+// latency and errors of the spans are still simulated. An error is returned when a failing span stops on fail.
+func runSpans(ctx context.Context, spans []config.Span) error {
+	for i := range spans {
+		if err := runSpan(ctx, &spans[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSpan(ctx context.Context, span *config.Span) error {
+	tracer := trace.Tracer(noop.NewTracerProvider().Tracer(""))
+	if otelActive {
+		tracer = otel.Tracer
+	}
+	kind, attrs := spanPreset(span)
+	ctx, s := tracer.Start(ctx, span.Name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+	defer s.End()
+
+	if d := span.GetDuration().Pick(); d > 0 {
+		slog.DebugContext(ctx, "span", "name", span.Name, "ms", d.Milliseconds())
+		time.Sleep(d)
+	}
+	err := runSpans(ctx, span.Spans)
+	if err == nil && span.ErrorRate > 0 && rand.Float64() < span.ErrorRate {
+		err = fmt.Errorf("simulated error in %s", span.Name)
+		if !span.StopOnFail {
+			recordSpanError(s, span, err)
+			return nil
+		}
+	}
+	if err != nil {
+		recordSpanError(s, span, err)
+	}
+	return err
+}
+
+func recordSpanError(s trace.Span, span *config.Span, err error) {
+	s.RecordError(err)
+	s.SetStatus(codes.Error, err.Error())
+	if span.Preset != "" {
+		s.SetAttributes(attribute.String("error.type", "simulated"))
+	}
+}
+
+// spanPreset returns the span kind and the semantic convention attributes of the preset, followed by the
+// configured attributes.
+func spanPreset(span *config.Span) (trace.SpanKind, []attribute.KeyValue) {
+	kind := trace.SpanKindInternal
+	var attrs []attribute.KeyValue
+	switch span.Preset {
+	case spanPresetDb, spanPresetCache:
+		kind = trace.SpanKindClient
+		system := span.System
+		if system == "" {
+			system = map[string]string{spanPresetDb: "postgresql", spanPresetCache: "redis"}[span.Preset]
+		}
+		operation := span.Operation
+		if operation == "" && span.Statement != "" {
+			operation = strings.ToUpper(strings.Fields(span.Statement)[0])
+		}
+		attrs = append(attrs, attribute.String("db.system", system))
+		attrs = appendNonEmpty(attrs, "db.name", span.Target)
+		attrs = appendNonEmpty(attrs, "db.operation", operation)
+		attrs = appendNonEmpty(attrs, "db.statement", span.Statement)
+	case spanPresetMessaging:
+		kind = trace.SpanKindProducer
+		system := span.System
+		if system == "" {
+			system = "kafka"
+		}
+		operation := span.Operation
+		if operation == "" {
+			operation = "publish"
+		}
+		attrs = append(attrs, attribute.String("messaging.system", system),
+			attribute.String("messaging.operation", operation))
+		attrs = appendNonEmpty(attrs, "messaging.destination.name", span.Target)
+	}
+	if k, ok := spanKinds[span.Kind]; ok {
+		kind = k
+	}
+	for key, value := range span.Attributes {
+		attrs = append(attrs, toAttribute(key, value))
+	}
+	return kind, attrs
+}
+
+func appendNonEmpty(attrs []attribute.KeyValue, key, value string) []attribute.KeyValue {
+	if value == "" {
+		return attrs
+	}
+	return append(attrs, attribute.String(key, value))
+}
+
+// toAttribute converts a config value, numbers are decoded as int64 or float64.
+func toAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case string:
+		return attribute.String(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
+
+// annotateSpan adds the rendered attributes and events of an endpoint or route to the span in ctx.
+func annotateSpan(ctx context.Context, annotations *util.SpanAnnotations, data map[string]interface{}) {
+	if !otelActive || annotations.IsEmpty() {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(stringAttributes(annotations.RenderAttributes(data))...)
+	for _, event := range annotations.RenderEvents(data) {
+		span.AddEvent(event.Name, trace.WithAttributes(stringAttributes(event.Attributes)...))
+	}
+}
+
+func stringAttributes(values map[string]string) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(values))
+	for key, value := range values {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	return attrs
+}
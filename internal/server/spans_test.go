@@ -0,0 +1,107 @@
+package server
+
+import (
+	"context"
+	"github.com/ravan/microservice-sim/internal/config"
+	"github.com/ravan/microservice-sim/internal/otel"
+	"github.com/ravan/microservice-sim/internal/util"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.Tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	otelActive = true
+	defer func() { otelActive = false }()
+
+	ctx, root := otel.Tracer.Start(context.Background(), "GET /orders")
+	err := runSpans(ctx, []config.Span{
+		{Name: "validate", Duration: "1ms-2ms", Attributes: map[string]interface{}{"order.items": int64(3)}},
+		{Name: "load", Spans: []config.Span{
+			{Name: "cache.get", Preset: "cache", Operation: "GET"},
+			{Name: "db.query SELECT orders", Preset: "db", Target: "shop", Statement: "select * from orders"},
+		}},
+		{Name: "publish", Preset: "messaging", Target: "orders", ErrorRate: 1},
+	})
+	root.End()
+	require.NoError(t, err, "errors only fail the request with stopOnFail")
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	require.Len(t, spans, 6)
+	require.Equal(t, spans["load"].SpanContext().SpanID(), spans["cache.get"].Parent().SpanID())
+	require.Equal(t, root.SpanContext().SpanID(), spans["validate"].Parent().SpanID())
+	require.Contains(t, spans["validate"].Attributes(), attribute.Int64("order.items", 3))
+
+	db := spans["db.query SELECT orders"]
+	require.Equal(t, trace.SpanKindClient, db.SpanKind())
+	require.Contains(t, db.Attributes(), attribute.String("db.system", "postgresql"))
+	require.Contains(t, db.Attributes(), attribute.String("db.name", "shop"))
+	require.Contains(t, db.Attributes(), attribute.String("db.operation", "SELECT"))
+	require.Contains(t, db.Attributes(), attribute.String("db.statement", "select * from orders"))
+	require.Contains(t, spans["cache.get"].Attributes(), attribute.String("db.system", "redis"))
+
+	publish := spans["publish"]
+	require.Equal(t, trace.SpanKindProducer, publish.SpanKind())
+	require.Contains(t, publish.Attributes(), attribute.String("messaging.destination.name", "orders"))
+	require.Equal(t, codes.Error, publish.Status().Code)
+
+	err = runSpans(ctx, []config.Span{{Name: "outer", Spans: []config.Span{{Name: "inner", ErrorRate: 1, StopOnFail: true}}}})
+	require.Error(t, err)
+}
+
+func TestAnnotateSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.Tracer = tp.Tracer("test")
+	otelActive = true
+	defer func() { otelActive = false }()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	mux := http.NewServeMux()
+	initEndpoints(mux, []config.Endpoint{{
+		Uri: "/orders",
+		SpanAnnotations: util.SpanAnnotations{
+			Attributes: map[string]string{"customer.id": `[[ .Request.Header.Get "X-Customer-Id" ]]`},
+			Events:     []util.SpanEvent{{Name: "order placed", Attributes: map[string]string{"service": "[[ .ServiceName ]]"}}},
+		},
+		Routes: []config.Route{{
+			Uri:             strings.TrimPrefix(backend.URL, "http://") + "/stock",
+			SpanAnnotations: util.SpanAnnotations{Attributes: map[string]string{"stock.check": "true"}},
+		}},
+	}})
+	srv := httptest.NewServer(otelhttp.NewHandler(mux, "server", otelhttp.WithTracerProvider(tp)))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/orders", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Customer-Id", "c-42")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	srv.Close()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	require.Contains(t, spans["server"].Attributes(), attribute.String("customer.id", "c-42"))
+	require.Len(t, spans["server"].Events(), 1)
+	require.Equal(t, "order placed", spans["server"].Events()[0].Name)
+	route := spans[strings.ReplaceAll(strings.TrimPrefix(backend.URL, "http://")+"/stock", "/", ".")]
+	require.NotNil(t, route)
+	require.Contains(t, route.Attributes(), attribute.String("stock.check", "true"))
+}
@@ -0,0 +1,172 @@
+package stress
+
+import (
+	"fmt"
+	"github.com/dustin/go-humanize"
+	psutil "github.com/shirou/gopsutil/mem"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Percentages of memory and cpu are resolved against the resource basis:
+//
+//	host    - memory and cores of the node
+//	limit   - cgroup memory limit and cpu quota, falling back to host
+//	request - configured memory and cpu requests, falling back to limit
+//	auto    - same as limit
+const (
+	BasisAuto    = "auto"
+	BasisHost    = "host"
+	BasisLimit   = "limit"
+	BasisRequest = "request"
+)
+
+type resourceBasis struct {
+	basis         string
+	memoryRequest uint64
+	cpuRequest    float64
+}
+
+var (
+	cgroupRoot = "/sys/fs/cgroup"
+	basis      = resourceBasis{basis: BasisAuto}
+)
+
+// SetResourceBasis configures how percentages are resolved. Requests are optional humanized memory sizes and
+// cpu cores like "0.5" or "500m", typically injected from the Kubernetes downward API.
+func SetResourceBasis(basisName, memoryRequest, cpuRequest string) error {
+	if basisName == "" {
+		basisName = BasisAuto
+	}
+	basis = resourceBasis{basis: basisName}
+	if memoryRequest != "" {
+		size, err := humanize.ParseBytes(memoryRequest)
+		if err != nil {
+			return fmt.Errorf("invalid memory request %s: %w", memoryRequest, err)
+		}
+		basis.memoryRequest = size
+	}
+	if cpuRequest != "" {
+		cores, err := parseCores(cpuRequest)
+		if err != nil {
+			return fmt.Errorf("invalid cpu request %s: %w", cpuRequest, err)
+		}
+		basis.cpuRequest = cores
+	}
+	return nil
+}
+
+// LogResourceBasis logs the memory and cores percentages are resolved against.
+func LogResourceBasis() {
+	memory, memorySource := MemoryTotal()
+	cores, coresSource := availableCores()
+	slog.Info("resource basis", "basis", basis.basis,
+		"memory", humanize.Bytes(memory), "memorySource", memorySource,
+		"cores", cores, "coresSource", coresSource)
+}
+
+// MemoryTotal returns the memory percentages are resolved against and where it was taken from.
+func MemoryTotal() (uint64, string) {
+	if basis.basis == BasisRequest && basis.memoryRequest > 0 {
+		return basis.memoryRequest, "request"
+	}
+	memInfo, _ := psutil.VirtualMemory()
+	if basis.basis != BasisHost {
+		if limit, ok := cgroupMemoryLimit(); ok && limit < memInfo.Total {
+			return limit, "cgroup limit"
+		}
+	}
+	return memInfo.Total, "host"
+}
+
+// AvailableCores returns the number of cores the process may use according to the resource basis.
+func AvailableCores() float64 {
+	cores, _ := availableCores()
+	return cores
+}
+
+func availableCores() (float64, string) {
+	if basis.basis == BasisRequest && basis.cpuRequest > 0 {
+		return basis.cpuRequest, "request"
+	}
+	cores := float64(runtime.NumCPU())
+	if basis.basis != BasisHost {
+		if quota, ok := cgroupCpuQuota(); ok && quota < cores {
+			return quota, "cgroup limit"
+		}
+	}
+	return cores, "host"
+}
+
+// cgroupMemoryLimit reads the memory limit from cgroup v2 "memory.max" or cgroup v1 "memory.limit_in_bytes".
+func cgroupMemoryLimit() (uint64, bool) {
+	content, err := os.ReadFile(filepath.Join(cgroupRoot, "memory.max"))
+	if err != nil {
+		content, err = os.ReadFile(filepath.Join(cgroupRoot, "memory", "memory.limit_in_bytes"))
+		if err != nil {
+			return 0, false
+		}
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return 0, false
+	}
+	limit, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || limit == 0 {
+		return 0, false
+	}
+	return limit, true
+}
+
+func parseCores(cores string) (float64, error) {
+	if millis, ok := strings.CutSuffix(cores, "m"); ok {
+		value, err := strconv.ParseFloat(millis, 64)
+		return value / 1000, err
+	}
+	return strconv.ParseFloat(cores, 64)
+}
+
+// cgroupCpuQuota reads the cpu quota in cores from cgroup v2 "cpu.max" or cgroup v1 "cpu.cfs_quota_us".
+func cgroupCpuQuota() (float64, bool) {
+	if content, err := os.ReadFile(filepath.Join(cgroupRoot, "cpu.max")); err == nil {
+		return parseCpuMax(string(content))
+	}
+	quota, err := readCgroupInt("cpu", "cpu.cfs_quota_us")
+	if err != nil || quota <= 0 {
+		return 0, false
+	}
+	period, err := readCgroupInt("cpu", "cpu.cfs_period_us")
+	if err != nil || period <= 0 {
+		return 0, false
+	}
+	return float64(quota) / float64(period), true
+}
+
+// parseCpuMax parses the cgroup v2 "$MAX $PERIOD" format where $MAX may be "max" for no limit.
+func parseCpuMax(content string) (float64, bool) {
+	fields := strings.Fields(content)
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0, false
+	}
+	quota, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, false
+	}
+	period, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || period <= 0 {
+		return 0, false
+	}
+	return quota / period, true
+}
+
+func readCgroupInt(controller, file string) (int64, error) {
+	content, err := os.ReadFile(filepath.Join(cgroupRoot, controller, file))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
@@ -0,0 +1,65 @@
+package stress
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCpuMax(t *testing.T) {
+	quota, ok := parseCpuMax("50000 100000\n")
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, quota)
+
+	_, ok = parseCpuMax("max 100000\n")
+	assert.False(t, ok)
+}
+
+func TestCgroupV1CpuQuota(t *testing.T) {
+	root := t.TempDir()
+	setCgroupRoot(t, root)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "cpu"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"), []byte("25000\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"), []byte("100000\n"), 0644))
+
+	assert.Equal(t, 0.25, AvailableCores())
+	workers, load := cpuWorkers(100)
+	assert.Equal(t, 1, workers)
+	assert.Equal(t, 0.25, load)
+}
+
+func setCgroupRoot(t *testing.T, root string) {
+	previous := cgroupRoot
+	cgroupRoot = root
+	t.Cleanup(func() {
+		cgroupRoot = previous
+	})
+}
+
+func TestResourceBasis(t *testing.T) {
+	root := t.TempDir()
+	setCgroupRoot(t, root)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "memory.max"), []byte("10485760\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "cpu.max"), []byte("50000 100000\n"), 0644))
+	t.Cleanup(func() {
+		basis = resourceBasis{basis: BasisAuto}
+	})
+
+	require.NoError(t, SetResourceBasis(BasisAuto, "", ""))
+	size, err := parseMemSize("10%")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1048576), size)
+	assert.Equal(t, 0.5, AvailableCores())
+
+	require.NoError(t, SetResourceBasis(BasisRequest, "8388608", "250m"))
+	memory, source := MemoryTotal()
+	assert.Equal(t, uint64(8388608), memory)
+	assert.Equal(t, "request", source)
+	assert.Equal(t, 0.25, AvailableCores())
+
+	require.NoError(t, SetResourceBasis(BasisHost, "", ""))
+	_, source = MemoryTotal()
+	assert.Equal(t, "host", source)
+}
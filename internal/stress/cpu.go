@@ -0,0 +1,72 @@
+package stress
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+const dutyCyclePeriod = 100 * time.Millisecond
+
+// Cpu loads a percentage of the available cores without relying on external tools.
+// The load ramps up linearly over rampTime and stops after duration. A zero duration runs forever.
+func Cpu(load float64, duration, rampTime time.Duration) {
+	workers, workerLoad := cpuWorkers(load)
+	slog.Debug("cpu stress workers", "cores", AvailableCores(), "workers", workers, "workerLoad", workerLoad)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cpuWorker(start, workerLoad, duration, rampTime)
+		}()
+	}
+	wg.Wait()
+}
+
+// CpuStressNgArgs translates a load percentage of the available cores to stress-ng cpu arguments.
+func CpuStressNgArgs(load float64, duration time.Duration) []string {
+	workers, workerLoad := cpuWorkers(load)
+	args := []string{"--cpu", fmt.Sprint(workers), "--cpu-load", fmt.Sprint(int(math.Round(workerLoad * 100)))}
+	if duration > 0 {
+		args = append(args, "--timeout", fmt.Sprintf("%ds", int(math.Ceil(duration.Seconds()))))
+	}
+	return args
+}
+
+// cpuWorkers spreads the load over one worker per available core, returning the number of workers and
+// the fraction of time each worker is busy.
+func cpuWorkers(load float64) (int, float64) {
+	cores := AvailableCores()
+	workers := int(math.Ceil(cores))
+	if workers < 1 {
+		workers = 1
+	}
+	workerLoad := math.Min(1, math.Max(0, load/100*cores/float64(workers)))
+	return workers, workerLoad
+}
+
+func cpuWorker(start time.Time, load float64, duration, rampTime time.Duration) {
+	sum := sha256.Sum256([]byte("mockroservice"))
+	for {
+		elapsed := time.Since(start)
+		if duration > 0 && elapsed >= duration {
+			return
+		}
+		current := load
+		if rampTime > 0 && elapsed < rampTime {
+			current = load * float64(elapsed) / float64(rampTime)
+		}
+		busy := time.Duration(float64(dutyCyclePeriod) * current)
+		periodStart := time.Now()
+		for time.Since(periodStart) < busy {
+			sum = sha256.Sum256(sum[:])
+		}
+		time.Sleep(dutyCyclePeriod - busy)
+	}
+}
@@ -0,0 +1,170 @@
+package stress
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// RequestMem allocates memory on every request. The allocation is either released right away,
+// creating garbage collector churn, or retained in a leak pool that grows with call volume.
+// LeakCap bounds the memory leaked by this allocator, not the whole pool.
+type RequestMem struct {
+	Size    uint64
+	Leak    bool
+	LeakCap uint64
+}
+
+type leakPool struct {
+	mu     sync.Mutex
+	chunks [][]byte
+	size   uint64
+	leaked map[*RequestMem]uint64
+}
+
+var memLeak = &leakPool{leaked: make(map[*RequestMem]uint64)}
+
+// NewRequestMem parses the allocation and leak cap sizes. An empty leak cap lets the pool grow until the process is killed.
+func NewRequestMem(allocate string, leak bool, leakCap string) (*RequestMem, error) {
+	size, err := parseMemSize(allocate)
+	if err != nil {
+		return nil, err
+	}
+	var capSize uint64
+	if leakCap != "" {
+		capSize, err = parseMemSize(leakCap)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &RequestMem{
+		Size:    size,
+		Leak:    leak,
+		LeakCap: capSize,
+	}, nil
+}
+
+func (m *RequestMem) Apply() {
+	if m.Size == 0 {
+		return
+	}
+	data := make([]byte, m.Size)
+	// touch every page so the allocation is backed by resident memory
+	pageSize := os.Getpagesize()
+	for i := 0; i < len(data); i += pageSize {
+		data[i] = 1
+	}
+	if m.Leak {
+		memLeak.add(m, data)
+	}
+}
+
+func (p *leakPool) add(m *RequestMem, data []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m.LeakCap > 0 && p.leaked[m]+uint64(len(data)) > m.LeakCap {
+		return
+	}
+	p.chunks = append(p.chunks, data)
+	p.leaked[m] += uint64(len(data))
+	p.size += uint64(len(data))
+}
+
+// LeakedMemory returns the number of bytes retained by the leak pool.
+func LeakedMemory() uint64 {
+	memLeak.mu.Lock()
+	defer memLeak.mu.Unlock()
+	return memLeak.size
+}
+
+// LeakRate leaks a number of resources on every request until the cap is reached. A cap of 0 leaks without bounds.
+// The cap bounds the resources leaked with this rate, so a rate is kept per endpoint.
+type LeakRate struct {
+	PerRequest int
+	Cap        int
+}
+
+type resourcePool struct {
+	mu     sync.Mutex
+	held   []any
+	leaked map[*LeakRate]int
+}
+
+var (
+	goroutineLeak  = newResourcePool()
+	fileLeak       = newResourcePool()
+	connectionLeak = newResourcePool()
+	blockForever   = make(chan struct{})
+)
+
+func newResourcePool() *resourcePool {
+	return &resourcePool{leaked: make(map[*LeakRate]int)}
+}
+
+// LeakGoroutines starts goroutines that block forever.
+func LeakGoroutines(rate *LeakRate) {
+	for i := 0; i < rate.PerRequest && goroutineLeak.tryAdd(rate, nil); i++ {
+		go func() {
+			<-blockForever
+		}()
+	}
+}
+
+// LeakFiles opens temp files that are never closed. The files are unlinked right away so only the
+// file descriptors pile up, not the disk usage. No file is created once the cap is reached.
+func LeakFiles(rate *LeakRate) error {
+	for i := 0; i < rate.PerRequest && !fileLeak.full(rate); i++ {
+		f, err := os.CreateTemp("", "sim-leak-")
+		if err != nil {
+			return err
+		}
+		_ = os.Remove(f.Name())
+		if !fileLeak.tryAdd(rate, f) {
+			return f.Close()
+		}
+	}
+	return nil
+}
+
+// LeakConnection retains the response body of an outbound call so its connection is never closed or reused.
+// It returns false when the cap is reached and the caller should close the body itself.
+func LeakConnection(rate *LeakRate, body io.Closer) bool {
+	return connectionLeak.tryAdd(rate, body)
+}
+
+func LeakedGoroutines() int {
+	return goroutineLeak.count()
+}
+
+func LeakedFiles() int {
+	return fileLeak.count()
+}
+
+func LeakedConnections() int {
+	return connectionLeak.count()
+}
+
+// tryAdd holds on to the resource unless the cap of the rate is reached.
+func (p *resourcePool) tryAdd(rate *LeakRate, resource any) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if rate.Cap > 0 && p.leaked[rate] >= rate.Cap {
+		return false
+	}
+	p.held = append(p.held, resource)
+	p.leaked[rate]++
+	return true
+}
+
+// full reports whether the cap of the rate is reached.
+func (p *resourcePool) full(rate *LeakRate) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return rate.Cap > 0 && p.leaked[rate] >= rate.Cap
+}
+
+func (p *resourcePool) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.held)
+}
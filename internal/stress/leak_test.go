@@ -0,0 +1,73 @@
+package stress
+
+import (
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRequestMemLeakCap(t *testing.T) {
+	mem, err := NewRequestMem("1 MB", true, "3 MB")
+	require.NoError(t, err)
+	before := LeakedMemory()
+	for i := 0; i < 5; i++ {
+		mem.Apply()
+	}
+	require.Equal(t, uint64(3*1000*1000), LeakedMemory()-before)
+}
+
+func TestResourceLeakCap(t *testing.T) {
+	rate := &LeakRate{PerRequest: 2, Cap: 5}
+	for i := 0; i < 4; i++ {
+		LeakGoroutines(rate)
+		require.NoError(t, LeakFiles(rate))
+	}
+	require.Equal(t, 5, LeakedGoroutines())
+	require.Equal(t, 5, LeakedFiles())
+}
+
+func TestLeakFilesAtCap(t *testing.T) {
+	rate := &LeakRate{PerRequest: 1, Cap: 1}
+	require.NoError(t, LeakFiles(rate))
+	t.Setenv("TMPDIR", filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, LeakFiles(rate))
+}
+
+func TestLeakCapPerEndpoint(t *testing.T) {
+	large, err := NewRequestMem("1 MB", true, "3 MB")
+	require.NoError(t, err)
+	small, err := NewRequestMem("1 MB", true, "2 MB")
+	require.NoError(t, err)
+	before := LeakedMemory()
+	for i := 0; i < 3; i++ {
+		large.Apply()
+	}
+	for i := 0; i < 3; i++ {
+		small.Apply()
+	}
+	require.Equal(t, uint64(5*1000*1000), LeakedMemory()-before)
+
+	pool := newResourcePool()
+	first, second := &LeakRate{PerRequest: 1, Cap: 3}, &LeakRate{PerRequest: 1, Cap: 2}
+	for i := 0; i < 3; i++ {
+		pool.tryAdd(first, nil)
+		pool.tryAdd(second, nil)
+	}
+	require.Equal(t, 5, pool.count())
+}
+
+func TestResourcePoolCapConcurrently(t *testing.T) {
+	pool := newResourcePool()
+	rate := &LeakRate{PerRequest: 1, Cap: 10}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pool.tryAdd(rate, nil)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 10, pool.count())
+}
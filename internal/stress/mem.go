@@ -3,79 +3,218 @@ package stress
 // Adapted from https://github.com/chaos-mesh/memStress/blob/master/main.go
 
 import (
+	"fmt"
 	"github.com/dustin/go-humanize"
-	psutil "github.com/shirou/gopsutil/mem"
-	"os"
+	"golang.org/x/sys/unix"
+	"log/slog"
+	"math"
 	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
-func linearGrow(data []byte, length uint64, timeLine time.Duration) {
-	startTime := time.Now()
-	endTime := startTime.Add(timeLine)
+const (
+	PatternLinear    = "linear"
+	PatternSawtooth  = "sawtooth"
+	PatternOscillate = "oscillate"
+	PatternStep      = "step"
+	PatternRelease   = "release"
+	PatternOom       = "oom"
 
-	var allocated uint64 = 0
-	pageSize := uint64(syscall.Getpagesize())
-	interval := time.Millisecond * 10
+	memTickInterval = 50 * time.Millisecond
+)
 
-	for {
-		now := time.Now()
-		if now.After(endTime) {
-			now = endTime
-		}
-		expected := length * uint64(now.Sub(startTime).Milliseconds()) / uint64(endTime.Sub(startTime).Milliseconds()) / pageSize
+// MemOptions describes the shape of the memory usage over time.
+// Sizes are humanized sizes like "10 MB" or a percentage of total memory like "10%".
+type MemOptions struct {
+	Pattern      string
+	MemSize      string
+	MinSize      string
+	GrowthTime   time.Duration
+	Period       time.Duration
+	StepSize     string
+	StepInterval time.Duration
+	HoldTime     time.Duration
+}
 
-		for i := allocated; uint64(i) < expected; i++ {
-			data[uint64(i)*pageSize] = 0
-		}
+var (
+	memStressTarget    atomic.Uint64
+	memStressAllocated atomic.Uint64
+)
 
-		allocated = expected
-		if now.Equal(endTime) {
-			break
-		} else {
-			time.Sleep(interval)
-		}
-	}
+// MemStressTarget returns the number of bytes the memory stress pattern currently asks for.
+func MemStressTarget() uint64 {
+	return memStressTarget.Load()
+}
 
+// MemStressAllocated returns the number of bytes the memory stress currently holds resident.
+func MemStressAllocated() uint64 {
+	return memStressAllocated.Load()
 }
 
-func run(length uint64, timeLine time.Duration) error {
-	data, err := syscall.Mmap(-1, 0, int(length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+// memPattern returns the number of bytes that should be allocated at the elapsed time.
+type memPattern func(elapsed time.Duration) uint64
+
+// memRegion is anonymous memory made resident by touching its pages and released with madvise.
+// It grows in chunks of chunkSize so unbounded patterns can keep allocating.
+type memRegion struct {
+	chunkSize uint64
+	chunks    [][]byte
+	size      uint64
+}
+
+func Mem(opts MemOptions) error {
+	pattern, maxSize, err := newMemPattern(opts)
 	if err != nil {
 		return err
 	}
-
-	if timeLine > time.Nanosecond {
-		linearGrow(data, length, timeLine)
-	} else {
-		sysPageSize := os.Getpagesize()
-		for i := 0; uint64(i) < length; i += sysPageSize {
-			data[i] = 0
-		}
+	if maxSize == 0 {
+		return fmt.Errorf("memory stress size %s must be larger than zero", opts.MemSize)
 	}
+	return run(pattern, maxSize)
+}
 
+func run(pattern memPattern, chunkSize uint64) error {
+	pageSize := uint64(syscall.Getpagesize())
+	region := &memRegion{chunkSize: (chunkSize + pageSize - 1) / pageSize * pageSize}
+	startTime := time.Now()
 	for {
-		time.Sleep(time.Second * 2)
+		target := pattern(time.Since(startTime))
+		memStressTarget.Store(target)
+		err := region.resize(target)
+		memStressAllocated.Store(region.size)
+		if err != nil {
+			return err
+		}
+		time.Sleep(memTickInterval)
 	}
 }
 
-func Mem(memSize string, timeLine time.Duration) error {
-	memInfo, _ := psutil.VirtualMemory()
-	var length uint64
+func newMemPattern(opts MemOptions) (memPattern, uint64, error) {
+	maxSize, err := parseMemSize(opts.MemSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	var minSize uint64
+	if opts.MinSize != "" {
+		if minSize, err = parseMemSize(opts.MinSize); err != nil {
+			return nil, 0, err
+		}
+	}
+	if minSize > maxSize {
+		return nil, 0, fmt.Errorf("memory stress min size %s is larger than %s", opts.MinSize, opts.MemSize)
+	}
+	span := float64(maxSize - minSize)
+	between := func(fraction float64) uint64 {
+		return minSize + uint64(span*math.Min(1, math.Max(0, fraction)))
+	}
+	linear := func(elapsed time.Duration) uint64 {
+		if opts.GrowthTime <= 0 {
+			return maxSize
+		}
+		return between(float64(elapsed) / float64(opts.GrowthTime))
+	}
 
-	if memSize[len(memSize)-1] != '%' {
-		var err error
-		length, err = humanize.ParseBytes(memSize)
-		if err != nil {
-			return err
+	switch opts.Pattern {
+	case "", PatternLinear:
+		return linear, maxSize, nil
+
+	case PatternSawtooth:
+		if opts.GrowthTime <= 0 {
+			return nil, 0, fmt.Errorf("memory stress pattern %s requires a growth time", opts.Pattern)
+		}
+		return func(elapsed time.Duration) uint64 {
+			return linear(elapsed % opts.GrowthTime)
+		}, maxSize, nil
+
+	case PatternOscillate:
+		period := opts.Period
+		if period <= 0 {
+			period = 2 * opts.GrowthTime
+		}
+		if period <= 0 {
+			return nil, 0, fmt.Errorf("memory stress pattern %s requires a period", opts.Pattern)
+		}
+		return func(elapsed time.Duration) uint64 {
+			return between((1 - math.Cos(2*math.Pi*float64(elapsed)/float64(period))) / 2)
+		}, maxSize, nil
+
+	case PatternStep:
+		if opts.StepInterval <= 0 {
+			return nil, 0, fmt.Errorf("memory stress pattern %s requires a step interval", opts.Pattern)
+		}
+		stepSize := (maxSize - minSize) / 10
+		if opts.StepSize != "" {
+			if stepSize, err = parseMemSize(opts.StepSize); err != nil {
+				return nil, 0, err
+			}
+		}
+		return func(elapsed time.Duration) uint64 {
+			steps := uint64(elapsed/opts.StepInterval) + 1
+			return min(maxSize, minSize+steps*stepSize)
+		}, maxSize, nil
+
+	case PatternRelease:
+		return func(elapsed time.Duration) uint64 {
+			if elapsed < opts.GrowthTime+opts.HoldTime {
+				return linear(elapsed)
+			}
+			return minSize
+		}, maxSize, nil
+
+	case PatternOom:
+		growthTime := opts.GrowthTime
+		if growthTime <= 0 {
+			growthTime = time.Second
 		}
-	} else {
-		percentage, err := strconv.ParseFloat(memSize[0:len(memSize)-1], 64)
+		return func(elapsed time.Duration) uint64 {
+			return minSize + uint64(float64(maxSize)*float64(elapsed)/float64(growthTime))
+		}, maxSize, nil
+	}
+	return nil, 0, fmt.Errorf("unknown memory stress pattern %s", opts.Pattern)
+}
+
+// resize touches pages until the region holds target bytes or releases the pages above target.
+func (r *memRegion) resize(target uint64) error {
+	pageSize := uint64(syscall.Getpagesize())
+	target = target / pageSize * pageSize
+	for uint64(len(r.chunks))*r.chunkSize < target {
+		chunk, err := syscall.Mmap(-1, 0, int(r.chunkSize), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
 		if err != nil {
 			return err
 		}
-		length = uint64(float64(memInfo.Total) / 100.0 * percentage)
+		r.chunks = append(r.chunks, chunk)
+	}
+
+	if target > r.size {
+		for offset := r.size; offset < target; offset += pageSize {
+			r.chunks[offset/r.chunkSize][offset%r.chunkSize] = 0
+		}
+	} else if target < r.size {
+		for offset := target; offset < r.size; {
+			chunk := r.chunks[offset/r.chunkSize]
+			start := offset % r.chunkSize
+			end := min(r.chunkSize, start+r.size-offset)
+			if err := unix.Madvise(chunk[start:end], unix.MADV_DONTNEED); err != nil {
+				slog.Error("failed to release memory", slog.Any("error", err))
+			}
+			offset += end - start
+		}
+	}
+	r.size = target
+	return nil
+}
+
+// parseMemSize converts a humanized size like "10 MB" or a percentage of the resource basis memory like "10%" to bytes.
+func parseMemSize(memSize string) (uint64, error) {
+	if memSize[len(memSize)-1] != '%' {
+		return humanize.ParseBytes(memSize)
+	}
+	percentage, err := strconv.ParseFloat(memSize[0:len(memSize)-1], 64)
+	if err != nil {
+		return 0, err
 	}
-	return run(length, timeLine)
+	total, _ := MemoryTotal()
+	return uint64(float64(total) / 100.0 * percentage), nil
 }
@@ -0,0 +1,53 @@
+package stress
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const mb = 1000 * 1000
+
+func TestMemPatterns(t *testing.T) {
+	sawtooth, _, err := newMemPattern(MemOptions{Pattern: PatternSawtooth, MemSize: "10 MB", MinSize: "2 MB", GrowthTime: 10 * time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2*mb), sawtooth(0))
+	assert.Equal(t, uint64(6*mb), sawtooth(5*time.Second))
+	assert.Equal(t, uint64(2*mb), sawtooth(10*time.Second))
+
+	oscillate, _, err := newMemPattern(MemOptions{Pattern: PatternOscillate, MemSize: "10 MB", Period: 4 * time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), oscillate(0))
+	assert.Equal(t, uint64(10*mb), oscillate(2*time.Second))
+
+	step, _, err := newMemPattern(MemOptions{Pattern: PatternStep, MemSize: "10 MB", StepSize: "4 MB", StepInterval: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4*mb), step(0))
+	assert.Equal(t, uint64(8*mb), step(time.Second))
+	assert.Equal(t, uint64(10*mb), step(5*time.Second))
+
+	release, _, err := newMemPattern(MemOptions{Pattern: PatternRelease, MemSize: "10 MB", GrowthTime: time.Second, HoldTime: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10*mb), release(1500*time.Millisecond))
+	assert.Equal(t, uint64(0), release(2*time.Second))
+
+	oom, _, err := newMemPattern(MemOptions{Pattern: PatternOom, MemSize: "10 MB", GrowthTime: time.Second})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(30*mb), oom(3*time.Second))
+
+	_, _, err = newMemPattern(MemOptions{Pattern: "zigzag", MemSize: "10 MB"})
+	require.Error(t, err)
+}
+
+func TestMemRegionResize(t *testing.T) {
+	region := &memRegion{chunkSize: 1 << 20}
+	require.NoError(t, region.resize(3<<20))
+	assert.Len(t, region.chunks, 3)
+	assert.Equal(t, uint64(3<<20), region.size)
+
+	require.NoError(t, region.resize(1<<19))
+	assert.Equal(t, uint64(1<<19), region.size)
+	require.NoError(t, region.resize(2<<20))
+	assert.Len(t, region.chunks, 3)
+}
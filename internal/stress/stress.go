@@ -1,14 +1,195 @@
 package stress
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"os/exec"
+	"sync"
+	"syscall"
+	"time"
 )
 
-func Stress(args []string) {
-	cmd := exec.Command("stress-ng", args...)
-	err := cmd.Run()
+const (
+	StateWaiting     = "waiting"
+	StateRunning     = "running"
+	StateIdle        = "idle"
+	StateStopped     = "stopped"
+	StateFailed      = "failed"
+	StateUnavailable = "unavailable"
+
+	killGracePeriod = 5 * time.Second
+)
+
+var ErrStressNgNotFound = errors.New("stress-ng binary not found in PATH, install stress-ng or use the native cpustress backend")
+
+// StressNg supervises a stress-ng child process. Each run is optionally bounded by Duration and
+// restarted after the Repeat interval. The output of stress-ng is written to slog at OutputLevel.
+type StressNg struct {
+	Args        []string
+	Duration    time.Duration
+	Repeat      time.Duration
+	OutputLevel slog.Level
+
+	mu        sync.Mutex
+	state     string
+	pid       int
+	runs      int
+	startedAt time.Time
+	err       error
+}
+
+type StressNgStatus struct {
+	State     string     `json:"state"`
+	Runs      int        `json:"runs"`
+	Pid       int        `json:"pid,omitempty"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// Run blocks until all runs completed or ctx is done, in which case the stress-ng process group is terminated.
+func (s *StressNg) Run(ctx context.Context) error {
+	if _, err := exec.LookPath("stress-ng"); err != nil {
+		s.setState(StateUnavailable, ErrStressNgNotFound)
+		return ErrStressNgNotFound
+	}
+	for {
+		err := s.runOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Error when running stress-ng", slog.Any("error", err))
+			s.setState(StateFailed, err)
+		}
+		if ctx.Err() != nil {
+			s.setState(StateStopped, nil)
+			return nil
+		}
+		if s.Repeat <= 0 {
+			if err == nil {
+				s.setState(StateStopped, nil)
+			}
+			return err
+		}
+		if err == nil {
+			s.setState(StateIdle, nil)
+		}
+		slog.Debug("stress-ng restart", "interval", s.Repeat)
+		select {
+		case <-ctx.Done():
+			s.setState(StateStopped, nil)
+			return nil
+		case <-time.After(s.Repeat):
+		}
+	}
+}
+
+func (s *StressNg) Status() StressNgStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := StressNgStatus{
+		State: s.state,
+		Runs:  s.runs,
+		Pid:   s.pid,
+	}
+	if s.state == "" {
+		status.State = StateWaiting
+	}
+	if !s.startedAt.IsZero() {
+		startedAt := s.startedAt
+		status.StartedAt = &startedAt
+	}
+	if s.err != nil {
+		status.Error = s.err.Error()
+	}
+	return status
+}
+
+func (s *StressNg) runOnce(ctx context.Context) error {
+	cmd := exec.Command("stress-ng", s.Args...)
+	// run in its own process group so stress-ng and its workers are terminated together
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.state = StateRunning
+	s.pid = cmd.Process.Pid
+	s.runs++
+	s.startedAt = time.Now()
+	s.err = nil
+	run := s.runs
+	s.mu.Unlock()
+	slog.Info("stress-ng started", "pid", cmd.Process.Pid, "run", run)
+
+	var output sync.WaitGroup
+	output.Add(2)
+	go s.logOutput(&output, stdout, "stdout")
+	go s.logOutput(&output, stderr, "stderr")
+
+	done := make(chan error, 1)
+	go func() {
+		output.Wait()
+		done <- cmd.Wait()
+	}()
+
+	var timeout <-chan time.Time
+	if s.Duration > 0 {
+		timeout = time.After(s.Duration)
+	}
+	select {
+	case err = <-done:
+	case <-timeout:
+		slog.Info("stress-ng duration reached", "pid", cmd.Process.Pid, "duration", s.Duration)
+		err = terminate(cmd, done)
+	case <-ctx.Done():
+		slog.Info("stopping stress-ng", "pid", cmd.Process.Pid)
+		err = terminate(cmd, done)
+	}
+
+	s.mu.Lock()
+	s.pid = 0
+	s.mu.Unlock()
+	return err
+}
+
+// terminate sends SIGTERM to the process group and SIGKILL if it did not exit within the grace period.
+func terminate(cmd *exec.Cmd, done <-chan error) error {
+	pgid := cmd.Process.Pid
+	_ = syscall.Kill(-pgid, syscall.SIGTERM)
+	select {
+	case <-done:
+		return nil
+	case <-time.After(killGracePeriod):
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+		<-done
+		return fmt.Errorf("stress-ng did not stop within %s and was killed", killGracePeriod)
+	}
+}
+
+func (s *StressNg) logOutput(wg *sync.WaitGroup, r io.Reader, stream string) {
+	defer wg.Done()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		slog.Log(context.Background(), s.OutputLevel, scanner.Text(), "source", "stress-ng", "stream", stream)
+	}
+}
+
+func (s *StressNg) setState(state string, err error) {
+	s.mu.Lock()
+	s.state = state
 	if err != nil {
-		slog.Error("Error when running stress-ng", slog.Any("error", err))
+		s.err = err
 	}
+	s.mu.Unlock()
 }
@@ -0,0 +1,39 @@
+package stress
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStressNgNotFound(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	s := &StressNg{}
+	require.ErrorIs(t, s.Run(context.Background()), ErrStressNgNotFound)
+	require.Equal(t, StateUnavailable, s.Status().State)
+}
+
+func TestStressNgDurationAndRepeat(t *testing.T) {
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho stress-ng dispatching hogs\nexec sleep 10\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stress-ng"), []byte(script), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	s := &StressNg{
+		Duration:    200 * time.Millisecond,
+		Repeat:      100 * time.Millisecond,
+		OutputLevel: slog.LevelInfo,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 700*time.Millisecond)
+	defer cancel()
+	require.NoError(t, s.Run(ctx))
+
+	status := s.Status()
+	require.Equal(t, StateStopped, status.State)
+	require.GreaterOrEqual(t, status.Runs, 2)
+	require.Zero(t, status.Pid)
+}
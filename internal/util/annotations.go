@@ -0,0 +1,70 @@
+package util
+
+import (
+	"strconv"
+	"sync"
+	"text/template"
+)
+
+// SpanAnnotations are attributes and events added to the span of an endpoint or route. Values are templates
+// rendered like log messages, attributes and event attributes rendering to an empty string are omitted.
+type SpanAnnotations struct {
+	Attributes map[string]string `mapstructure:"attributes"`
+	Events     []SpanEvent       `mapstructure:"events"`
+	templates  map[string]*template.Template
+	mutex      sync.Mutex
+}
+
+type SpanEvent struct {
+	Name       string            `mapstructure:"name" validate:"required"`
+	Attributes map[string]string `mapstructure:"attributes"`
+}
+
+// RenderedEvent is a SpanEvent with rendered attributes.
+type RenderedEvent struct {
+	Name       string
+	Attributes map[string]string
+}
+
+func (a *SpanAnnotations) IsEmpty() bool {
+	return len(a.Attributes) == 0 && len(a.Events) == 0
+}
+
+func (a *SpanAnnotations) RenderAttributes(data any) map[string]string {
+	return a.render("attributes.", a.Attributes, data)
+}
+
+func (a *SpanAnnotations) RenderEvents(data any) []RenderedEvent {
+	events := make([]RenderedEvent, 0, len(a.Events))
+	for i, event := range a.Events {
+		events = append(events, RenderedEvent{
+			Name:       event.Name,
+			Attributes: a.render("events."+strconv.Itoa(i)+".", event.Attributes, data),
+		})
+	}
+	return events
+}
+
+func (a *SpanAnnotations) render(prefix string, values map[string]string, data any) map[string]string {
+	rendered := make(map[string]string, len(values))
+	for key, value := range values {
+		if v := renderTemplate(a.getTemplate(prefix+key, value), data); v != "" {
+			rendered[key] = v
+		}
+	}
+	return rendered
+}
+
+func (a *SpanAnnotations) getTemplate(name, value string) *template.Template {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.templates == nil {
+		a.templates = map[string]*template.Template{}
+	}
+	tpl, ok := a.templates[name]
+	if !ok {
+		tpl = parseTemplate(name, value)
+		a.templates[name] = tpl
+	}
+	return tpl
+}
@@ -0,0 +1,26 @@
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestSpanAnnotations(t *testing.T) {
+	annotations := SpanAnnotations{
+		Attributes: map[string]string{
+			"customer.id": `[[ .Request.Header.Get "X-Customer-Id" ]]`,
+			"service":     "[[ .ServiceName | upper ]]",
+			"order.id":    `[[ .Request.Header.Get "X-Order-Id" ]]`,
+		},
+		Events: []SpanEvent{{Name: "order placed", Attributes: map[string]string{"channel": "web"}}},
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://localhost/orders", nil)
+	req.Header.Set("X-Customer-Id", "c-42")
+	data := map[string]any{"ServiceName": "shop", "Request": req}
+
+	assert.Equal(t, map[string]string{"customer.id": "c-42", "service": "SHOP"}, annotations.RenderAttributes(data))
+	assert.Equal(t, []RenderedEvent{{Name: "order placed", Attributes: map[string]string{"channel": "web"}}},
+		annotations.RenderEvents(data))
+	assert.True(t, (&SpanAnnotations{}).IsEmpty())
+}
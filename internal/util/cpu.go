@@ -0,0 +1,99 @@
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// CpuWork burns cpu while a request is processed so latency translates into real cpu usage.
+// Work is either an amount of cpu time or a number of sha256 iterations.
+type CpuWork struct {
+	Enabled    bool
+	Duration   time.Duration
+	Iterations int
+}
+
+func (c *CpuWork) Apply(ctx context.Context, service, callTarget string) {
+	if !c.Enabled {
+		return
+	}
+	var sum [sha256.Size]byte
+	if c.Iterations > 0 {
+		slog.DebugContext(ctx, "cpu work", "service", service, "iterations", c.Iterations, "target", callTarget)
+		sum = hashIterations(c.Iterations)
+	} else if c.Duration > 0 {
+		slog.DebugContext(ctx, "cpu work", "service", service, "ms", c.Duration.Milliseconds(), "target", callTarget)
+		sum = burnCpu(c.Duration)
+	}
+	// keep the compiler from optimising the hashing loop away
+	runtime.KeepAlive(sum)
+}
+
+// ParseCpuWork accepts a duration of cpu time ("20ms") or a plain number of hash iterations ("5000").
+func ParseCpuWork(work string) *CpuWork {
+	if work == "" {
+		return &CpuWork{}
+	}
+	if iterations, err := strconv.Atoi(work); err == nil {
+		return &CpuWork{
+			Enabled:    iterations > 0,
+			Iterations: iterations,
+		}
+	}
+	duration, err := time.ParseDuration(work)
+	if err != nil {
+		slog.Error("failed to parse cpu work", "cpuWork", work, slog.Any("error", err))
+		return &CpuWork{}
+	}
+	return &CpuWork{
+		Enabled:  duration > 0,
+		Duration: duration,
+	}
+}
+
+func hashIterations(iterations int) [sha256.Size]byte {
+	sum := sha256.Sum256([]byte("mockroservice"))
+	for i := 0; i < iterations; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+	return sum
+}
+
+// burnCpu hashes until the calling thread consumed the given cpu time. Measuring thread cpu time
+// instead of wall time means a throttled container takes longer to finish the same amount of work.
+func burnCpu(duration time.Duration) [sha256.Size]byte {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	elapsed := threadCpuClock()
+	start := elapsed()
+	sum := sha256.Sum256([]byte("mockroservice"))
+	for elapsed()-start < duration {
+		for i := 0; i < 100; i++ {
+			sum = sha256.Sum256(sum[:])
+		}
+	}
+	return sum
+}
+
+// threadCpuClock returns the cpu time of the current thread, falling back to wall time
+// where the thread cpu clock is not available.
+func threadCpuClock() func() time.Duration {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_THREAD_CPUTIME_ID, &ts); err != nil {
+		start := time.Now()
+		return func() time.Duration {
+			return time.Since(start)
+		}
+	}
+	return func() time.Duration {
+		_ = unix.ClockGettime(unix.CLOCK_THREAD_CPUTIME_ID, &ts)
+		return time.Duration(ts.Nano())
+	}
+}
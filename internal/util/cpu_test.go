@@ -0,0 +1,41 @@
+package util
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseCpuWork(t *testing.T) {
+	work := ParseCpuWork("20ms")
+	assert.True(t, work.Enabled)
+	assert.Equal(t, 20*time.Millisecond, work.Duration)
+
+	work = ParseCpuWork("5000")
+	assert.True(t, work.Enabled)
+	assert.Equal(t, 5000, work.Iterations)
+
+	assert.False(t, ParseCpuWork("").Enabled)
+	assert.False(t, ParseCpuWork("lots").Enabled)
+}
+
+func TestBurnCpu(t *testing.T) {
+	start := time.Now()
+	ParseCpuWork("50ms").Apply(context.Background(), "test", "self")
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestApplyConcurrently(t *testing.T) {
+	work := ParseCpuWork("1000")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work.Apply(context.Background(), "test", "self")
+		}()
+	}
+	wg.Wait()
+}
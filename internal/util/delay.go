@@ -1,6 +1,7 @@
 package util
 
 import (
+	"context"
 	"log/slog"
 	"regexp"
 	"time"
@@ -12,16 +13,16 @@ type Delay struct {
 	AfterDuration  time.Duration
 }
 
-func (d *Delay) ApplyBefore(service, callTarget string) {
+func (d *Delay) ApplyBefore(ctx context.Context, service, callTarget string) {
 	if d.Enabled && d.BeforeDuration.Milliseconds() > 0 {
-		slog.Debug("latency before", "service", service, "ms", d.BeforeDuration.Milliseconds(), "target", callTarget)
+		slog.DebugContext(ctx, "latency before", "service", service, "ms", d.BeforeDuration.Milliseconds(), "target", callTarget)
 		time.Sleep(d.BeforeDuration)
 	}
 }
 
-func (d *Delay) ApplyAfter(service, callTarget string) {
+func (d *Delay) ApplyAfter(ctx context.Context, service, callTarget string) {
 	if d.Enabled && d.AfterDuration.Milliseconds() > 0 {
-		slog.Debug("latency after", "service", service, "ms", d.AfterDuration.Milliseconds(), "target", callTarget)
+		slog.DebugContext(ctx, "latency after", "service", service, "ms", d.AfterDuration.Milliseconds(), "target", callTarget)
 		time.Sleep(d.AfterDuration)
 	}
 }
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// DurationRange is a fixed duration or, when Max is larger than Min, a duration picked uniformly between both.
+type DurationRange struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// Pick returns the duration to spend.
+func (d *DurationRange) Pick() time.Duration {
+	if d.Max <= d.Min {
+		return d.Min
+	}
+	return d.Min + rand.N(d.Max-d.Min)
+}
+
+// ParseDurationRange accepts a duration ("5ms") or a range ("5ms-20ms").
+func ParseDurationRange(duration string) *DurationRange {
+	if duration == "" {
+		return &DurationRange{}
+	}
+	low, high, isRange := strings.Cut(duration, "-")
+	minDuration, err := time.ParseDuration(strings.TrimSpace(low))
+	if err != nil {
+		slog.Error("failed to parse duration", "duration", duration, slog.Any("error", err))
+		return &DurationRange{}
+	}
+	maxDuration := minDuration
+	if isRange {
+		maxDuration, err = time.ParseDuration(strings.TrimSpace(high))
+		if err != nil || maxDuration < minDuration {
+			slog.Error("invalid duration range", "duration", duration)
+			return &DurationRange{Min: minDuration, Max: minDuration}
+		}
+	}
+	return &DurationRange{Min: minDuration, Max: maxDuration}
+}
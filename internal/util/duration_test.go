@@ -0,0 +1,25 @@
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseDurationRange(t *testing.T) {
+	d := ParseDurationRange("5ms")
+	assert.Equal(t, 5*time.Millisecond, d.Pick())
+
+	d = ParseDurationRange("5ms-20ms")
+	assert.Equal(t, 5*time.Millisecond, d.Min)
+	assert.Equal(t, 20*time.Millisecond, d.Max)
+	for range 100 {
+		picked := d.Pick()
+		assert.GreaterOrEqual(t, picked, 5*time.Millisecond)
+		assert.Less(t, picked, 20*time.Millisecond)
+	}
+
+	assert.Zero(t, ParseDurationRange("").Pick())
+	assert.Zero(t, ParseDurationRange("soon").Pick())
+	assert.Equal(t, 20*time.Millisecond, ParseDurationRange("20ms-5ms").Pick())
+}
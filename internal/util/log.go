@@ -2,6 +2,7 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"github.com/Masterminds/sprig/v3"
 	"log/slog"
 	"strings"
@@ -37,15 +38,16 @@ func (l *Logging) GetLogAfterMsg(data any) string {
 	return renderTemplate(l.getAfterTemplate(), data)
 }
 
-func (l *Logging) LogBefore(data any) {
+// LogBefore logs the before message. The ctx correlates the message with the active span when logs are exported.
+func (l *Logging) LogBefore(ctx context.Context, data any) {
 	if l.Before != "" && l.shouldLog(false) {
-		logOutput(l.BeforeLevel, renderTemplate(l.getBeforeTemplate(), data))
+		logOutput(ctx, l.BeforeLevel, renderTemplate(l.getBeforeTemplate(), data))
 	}
 }
 
-func (l *Logging) LogAfter(data any) {
+func (l *Logging) LogAfter(ctx context.Context, data any) {
 	if l.After != "" && l.shouldLog(true) {
-		logOutput(l.AfterLevel, renderTemplate(l.getAfterTemplate(), data))
+		logOutput(ctx, l.AfterLevel, renderTemplate(l.getAfterTemplate(), data))
 	}
 }
 
@@ -118,18 +120,19 @@ func renderTemplate(tpl *template.Template, data any) string {
 	return strings.TrimSpace(out.String())
 }
 
-func logOutput(level, lines string) {
-	level = strings.ToLower(level)
+func logOutput(ctx context.Context, level, lines string) {
+	var logLevel slog.Level
+	switch strings.ToLower(level) {
+	case "debug":
+		logLevel = slog.LevelDebug
+	case "warn":
+		logLevel = slog.LevelWarn
+	case "error":
+		logLevel = slog.LevelError
+	default:
+		logLevel = slog.LevelInfo
+	}
 	for _, line := range strings.Split(lines, "\n") {
-		switch level {
-		case "debug":
-			slog.Debug(line)
-		case "warn":
-			slog.Warn(line)
-		case "error":
-			slog.Error(line)
-		default:
-			slog.Info(line)
-		}
+		slog.Log(ctx, logLevel, line)
 	}
 }